@@ -6,7 +6,15 @@
 package clientx
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/time/rate"
@@ -20,23 +28,98 @@ import (
 type API struct {
 	httpClient *http.Client
 	options    *Options
-	retry      Retrier
+	// newRetrier creates retry state of each request, it's nil if retrying is disabled.
+	newRetrier func() Retrier
 	limiter    Limiter
+	sem        chan struct{}
+	// lastBaseURL is a base URL of the last successful response.
+	lastBaseURL atomic.Value
+	connStats   *connTracker
+	// keyedLimiters contains rate limit buckets selected by RateLimitKeyFunc.
+	keyedLimiters *keyedLimiter
+	// rateLimitBases contains original limits (rateLimitBase) of limiters adapted by RateLimitParseFn.
+	rateLimitBases sync.Map
 }
 
 type (
 	Option  func(*Options)
 	Options struct {
-		BaseURL    string
+		BaseURL string
+		// Endpoints are named base URLs which can be selected per request.
+		Endpoints map[string]string
+		// Failover is a list of secondary base URLs used when requests to the base URL fail.
+		Failover   []string
 		HttpClient *http.Client
-		Headers    http.Header
-		// Debug prints responses into os.Stdout.
+		// TransportOptions are applied to cloned transport of HttpClient.
+		TransportOptions []TransportOption
+		// ConnPoolStats enables tracking of connection pool usage.
+		ConnPoolStats bool
+		Headers       http.Header
+		// RequestOptions are applied to every request before its own request options.
+		RequestOptions []RequestOption
+		// BodyPolicies override default handling of request bodies per method (see WithBodyPolicy).
+		BodyPolicies map[string]BodyPolicy
+		// QueryEncoders render values of field types in query params set by RequestBuilder.WithStructQueryParams.
+		QueryEncoders map[reflect.Type]func(reflect.Value) string
+		// AcceptStatus are ranges of accepted response status codes, other ones fail with StatusError.
+		// Any status is accepted if it's empty.
+		AcceptStatus []StatusRange
+		// EncoderDecoder is used by Do, DoWithDecode, etc. if encoder isn't passed per call, JSON by default.
+		EncoderDecoder EncoderDecoder
+		// Debug prints requests and responses into os.Stdout, or into Logger with debug level if it's set.
 		Debug bool
+		// DisableDecompression returns response bodies as is, regardless of Content-Encoding.
+		DisableDecompression bool
+		// DecompressContentTypes limits decompression to responses with listed media types.
+		DecompressContentTypes []string
+		// AcceptCharset is a list of charsets sent in Accept-Charset header. If set, response bodies
+		// are transcoded into UTF-8 before decoding.
+		AcceptCharset []string
+		// DecompressFallback makes responses with invalid Content-Encoding to be read as is instead of failing.
+		DecompressFallback bool
+		// Logger is used to report non-critical errors (warn level), e.g. failures of best-effort hooks,
+		// and debug dumps (debug level). Takes over os.Stdout when Debug is enabled.
+		Logger *slog.Logger
+		// RequestLogger logs summary of each completed request with info level.
+		RequestLogger *slog.Logger
+		// Observers receive events of every request (see WithObserver).
+		Observers []Observer
 		// RateLimitParseFn is a custom function that parses rate limits from HTTP response.
 		// For example from X-Ratelimit-Limit, X-Ratelimit-Remaining headers.
-		RateLimitParseFn func(*http.Response) (limit int, remaining int, resetAt time.Time, err error)
+		// The body contains buffered response body for APIs that report quota in payload.
+		RateLimitParseFn func(resp *http.Response, body []byte) (limit int, remaining int, resetAt time.Time, err error)
 		RateLimit        *OptionRateLimit
-		Retry            *OptionRetry
+		// RateLimitKeyFunc selects rate limit bucket of request, empty key selects the shared bucket.
+		RateLimitKeyFunc func(*http.Request) string
+		// KeyRateLimits are limits of buckets selected by RateLimitKeyFunc, other buckets use RateLimit.
+		KeyRateLimits map[string]*OptionRateLimit
+		// MaxQueueDepth is a maximum number of requests waiting for a rate limit token.
+		MaxQueueDepth int
+		// BandwidthLimit is a maximum throughput of request and response bodies in bytes per second.
+		BandwidthLimit int
+		// MaxConcurrency is a maximum number of requests performed simultaneously.
+		MaxConcurrency int
+		// OperationTimeout is a maximum duration of the whole operation: rate limit wait, retries and decoding.
+		OperationTimeout time.Duration
+		// RateLimitWaitTimeout is a maximum time to wait for a rate limit token.
+		RateLimitWaitTimeout time.Duration
+		Retry                *OptionRetry
+		// Hedging sends additional requests if response is slow (see WithHedging).
+		Hedging *OptionHedging
+		// Limiter replaces built-in rate limiter, RateLimit is ignored if it's set.
+		Limiter Limiter
+		// NewRetrier creates Retrier used instead of built-in backoff (see WithRetrier).
+		NewRetrier func() Retrier
+		RequestID  *OptionRequestID
+		// Cache stores responses of GET requests according to Cache-Control and Expires headers.
+		Cache Cache
+		// CacheKeyFunc computes cache key of request, DefaultCacheKey is used if not specified.
+		CacheKeyFunc func(*http.Request) string
+	}
+
+	// StatusRange is an inclusive range of status codes.
+	StatusRange struct {
+		Min, Max int
 	}
 
 	OptionRateLimit struct {
@@ -46,6 +129,13 @@ type (
 		Per time.Duration
 	}
 
+	OptionRequestID struct {
+		// Header is a header name to pass request ID.
+		Header string
+		// Generate generates new request ID.
+		Generate func() string
+	}
+
 	OptionRetry struct {
 		MaxAttempts int
 		MinWaitTime time.Duration
@@ -54,6 +144,16 @@ type (
 		Conditions []RetryCond
 		// Retry function which will be used as main retry logic.
 		Fn RetryFunc
+		// Guards are checked before each retry, all of them have to allow the retry.
+		Guards []RetryGuard
+		// IdempotentOnly disables retries of non-idempotent methods (POST, PATCH) without Idempotency-Key header.
+		IdempotentOnly bool
+		// DelayFn extracts server-provided retry delay from response body (see WithRetryDelayFromBody).
+		DelayFn RetryDelayFunc
+		// RespectRetryAfter makes delay from Retry-After header preferred over computed backoff.
+		RespectRetryAfter bool
+		// Jitter randomizes delay computed by Fn (see WithRetryJitter).
+		Jitter Jitter
 	}
 )
 
@@ -67,30 +167,64 @@ func NewAPI(opts ...Option) *API {
 		opt(options)
 	}
 
+	transportOptions := options.TransportOptions
+	var connStats *connTracker
+	if options.ConnPoolStats {
+		connStats = new(connTracker)
+		// Must go last to wrap custom DialContext
+		transportOptions = append(transportOptions[:len(transportOptions):len(transportOptions)], connStats.transportOption)
+	}
+
 	api := &API{
-		httpClient: options.HttpClient,
+		httpClient: buildHTTPClient(options.HttpClient, transportOptions),
 		options:    options,
+		connStats:  connStats,
 	}
-	if options.Retry != nil {
-		api.retry = &backoff{
-			minWaitTime: options.Retry.MinWaitTime,
-			maxWaitTime: options.Retry.MaxWaitTime,
-			maxAttempts: int64(options.Retry.MaxAttempts),
-			attempts:    0,
-			f:           options.Retry.Fn,
+	if options.NewRetrier != nil {
+		api.newRetrier = options.NewRetrier
+	} else if options.Retry != nil {
+		retry := *options.Retry
+		api.newRetrier = func() Retrier {
+			return &backoff{
+				minWaitTime: retry.MinWaitTime,
+				maxWaitTime: retry.MaxWaitTime,
+				maxAttempts: int64(retry.MaxAttempts),
+				attempts:    0,
+				f:           retry.Fn,
+				jitter:      retry.Jitter,
+			}
 		}
 	}
-	if options.RateLimit != nil {
-		limit := rate.Every(options.RateLimit.Per / time.Duration(options.RateLimit.Limit))
-		api.limiter = newAdaptiveBucketLimiter(limit, options.RateLimit.Burst)
+	if options.Limiter != nil {
+		api.limiter = options.Limiter
 	} else {
-		api.limiter = newUnlimitedAdaptiveBucketLimiter()
+		api.limiter = newRateLimiter(options.RateLimit)
+	}
+	if options.MaxConcurrency > 0 {
+		api.sem = make(chan struct{}, options.MaxConcurrency)
+	}
+	if options.MaxQueueDepth > 0 {
+		api.limiter = newQueueLimiter(api.limiter, options.MaxQueueDepth)
+	}
+	if options.RateLimitKeyFunc != nil {
+		api.keyedLimiters = newKeyedLimiter(func(key string) Limiter {
+			rl := options.RateLimit
+			if keyed, ok := options.KeyRateLimits[key]; ok {
+				rl = keyed
+			}
+			l := Limiter(newRateLimiter(rl))
+			if options.MaxQueueDepth > 0 {
+				l = newQueueLimiter(l, options.MaxQueueDepth)
+			}
+			return l
+		})
 	}
 
 	return api
 }
 
-// WithDebug enables debug logging of requests and responses.
+// WithDebug enables debug logging of requests and responses. If Logger is set (see WithLogger),
+// dumps are written into it with debug level instead of os.Stdout, so handler level controls verbosity.
 // DO NOT USE IN PRODUCTION.
 func WithDebug() Option {
 	return func(o *Options) {
@@ -98,13 +232,88 @@ func WithDebug() Option {
 	}
 }
 
-// WithBaseURL sets base URL to perform requests.
+// WithLogger sets logger to report non-critical errors.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *Options) {
+		o.Logger = logger
+	}
+}
+
+// WithNoDecompression disables automatic decompression of response bodies (both by clientx and by transport),
+// so raw compressed bytes are returned, e.g. to store a downloaded .gz artifact as is.
+func WithNoDecompression() Option {
+	return func(o *Options) {
+		o.DisableDecompression = true
+		o.TransportOptions = append(o.TransportOptions, func(t *http.Transport) {
+			t.DisableCompression = true
+		})
+	}
+}
+
+// WithDecompressContentTypes enables automatic decompression only for responses with listed media types
+// (e.g. "application/json"), bodies of other responses are returned as is. Media type parameters are ignored.
+func WithDecompressContentTypes(contentTypes ...string) Option {
+	return func(o *Options) {
+		o.DecompressContentTypes = append(o.DecompressContentTypes, contentTypes...)
+	}
+}
+
+// WithDecompressFallback enables falling back to raw response body when body can't be decompressed
+// according to Content-Encoding header (e.g. plain error page mislabeled as gzip). Logs a warning in such case.
+func WithDecompressFallback() Option {
+	return func(o *Options) {
+		o.DecompressFallback = true
+	}
+}
+
+// WithDefaultEncoderDecoder sets EncoderDecoder used by Do, DoWithDecode and other methods of RequestBuilder
+// when encoder isn't passed per call, e.g. XMLEncoderDecoder for XML-only APIs. JSON is used by default.
+func WithDefaultEncoderDecoder(enc EncoderDecoder) Option {
+	return func(o *Options) {
+		o.EncoderDecoder = enc
+	}
+}
+
+// WithAcceptStatus adds inclusive range of accepted response status codes, e.g. WithAcceptStatus(200, 399)
+// for APIs which return 3xx on success. Once accepted statuses are set, responses with other status codes fail
+// with StatusError, which is checked after error decoding function (see RequestBuilder.WithErrorDecode).
+// Note that http.Client follows redirects unless its CheckRedirect returns http.ErrUseLastResponse.
+func WithAcceptStatus(min, max int) Option {
+	return func(o *Options) {
+		o.AcceptStatus = append(o.AcceptStatus, StatusRange{Min: min, Max: max})
+	}
+}
+
+// WithAcceptStatusCodes adds accepted response status codes (see WithAcceptStatus).
+func WithAcceptStatusCodes(codes ...int) Option {
+	return func(o *Options) {
+		for _, code := range codes {
+			o.AcceptStatus = append(o.AcceptStatus, StatusRange{Min: code, Max: code})
+		}
+	}
+}
+
+// WithBaseURL sets base URL to perform requests. Resource paths are appended to path of base URL,
+// e.g. "https://example.com/v1" and "/users" are joined into "https://example.com/v1/users".
+// Query of base URL (e.g. "?apikey=abc") is kept, query params of request options are merged into it.
 func WithBaseURL(url string) Option {
 	return func(o *Options) {
 		o.BaseURL = url
 	}
 }
 
+// WithNamedBaseURL registers named base URL (endpoint), e.g. "fallback" host. Requests use BaseURL
+// unless endpoint is selected per request via RequestBuilder.WithEndpoint. All endpoints share
+// the same retry, rate limit and headers configuration.
+func WithNamedBaseURL(name, url string) Option {
+	return func(o *Options) {
+		if o.Endpoints == nil {
+			o.Endpoints = make(map[string]string)
+		}
+		o.Endpoints[name] = url
+	}
+}
+
 // WithHTTPClient allows you to specify a custom http.Client to use for making requests.
 // This is useful if you want to use a custom transport or proxy.
 func WithHTTPClient(client *http.Client) Option {
@@ -120,12 +329,219 @@ func WithRetry(maxAttempts int, minWaitTime, maxWaitTime time.Duration, f RetryF
 		if f == nil {
 			f = ExponentalBackoff // uses as default
 		}
-		o.Retry = &OptionRetry{
-			MaxAttempts: maxAttempts,
-			MinWaitTime: minWaitTime,
-			MaxWaitTime: maxWaitTime,
-			Conditions:  conditions,
-			Fn:          f,
+		retry := o.retry()
+		retry.MaxAttempts = maxAttempts
+		retry.MinWaitTime = minWaitTime
+		retry.MaxWaitTime = maxWaitTime
+		retry.Conditions = conditions
+		retry.Fn = f
+	}
+}
+
+// WithRetrier sets custom Retrier implementation, e.g. test double with predefined delays, instead of
+// built-in backoff. Also enables retrying mechanism, retry conditions are set by WithRetry or per request.
+// The newRetrier is called for each request, so retry state isn't shared between concurrent requests.
+func WithRetrier(newRetrier func() Retrier) Option {
+	return func(o *Options) {
+		o.retry()
+		o.NewRetrier = newRetrier
+	}
+}
+
+// WithLimiter sets custom Limiter implementation instead of built-in rate limiter, e.g. test double
+// which never blocks. Rate limits set by WithRateLimit are ignored.
+func WithLimiter(l Limiter) Option {
+	return func(o *Options) {
+		o.Limiter = l
+	}
+}
+
+// WithRetryIdempotentOnly disables retries of POST and PATCH requests, unless Idempotency-Key header is set,
+// because repeating them may cause duplicate side effects. Idempotent methods (GET, PUT, DELETE, etc.)
+// are retried as usual. Has effect only with WithRetry.
+func WithRetryIdempotentOnly() Option {
+	return func(o *Options) {
+		o.retry().IdempotentOnly = true
+	}
+}
+
+// WithRetryGuards adds guards checked before each retry, e.g. RetryIfTimeLeft to avoid starting
+// an attempt that can't finish before context deadline. Has effect only with WithRetry.
+func WithRetryGuards(guards ...RetryGuard) Option {
+	return func(o *Options) {
+		retry := o.retry()
+		retry.Guards = append(retry.Guards, guards...)
+	}
+}
+
+// WithRetryJitter sets Jitter applied to delays computed by retry function, e.g. FullJitter. Retry function
+// should compute delays without its own jitter then:
+//
+//	clientx.WithRetry(5, time.Second, time.Minute, clientx.NewExponentalBackoff(clientx.BackoffRand(func() float64 { return 0 }))),
+//	clientx.WithRetryJitter(clientx.FullJitter),
+//
+// Has effect only with WithRetry, custom Retrier (see WithRetrier) isn't affected.
+func WithRetryJitter(j Jitter) Option {
+	return func(o *Options) {
+		o.retry().Jitter = j
+	}
+}
+
+// WithRespectRetryAfter makes retries wait for delay from Retry-After header (delay-seconds or HTTP-date form)
+// instead of computed backoff, if the header is present in response which triggered the retry (e.g. 429 or 503).
+// Malformed header falls back to the backoff, date in the past means no wait. The delay is bounded by
+// maximal wait time. Has effect only with WithRetry.
+func WithRespectRetryAfter(enable bool) Option {
+	return func(o *Options) {
+		o.retry().RespectRetryAfter = enable
+	}
+}
+
+// WithRetryDelayFromBody sets function which extracts retry delay hint from buffered response body,
+// e.g. {"retry_after_ms": 500} returned by polling APIs with 200 status. If f reports a hint,
+// the request is retried (as if retry condition matched) after the hinted delay instead of the backoff one.
+// The delay is bounded by MaxWaitTime and retries are still limited by MaxAttempts. Has effect only with WithRetry.
+func WithRetryDelayFromBody(f RetryDelayFunc) Option {
+	return func(o *Options) {
+		o.retry().DelayFn = f
+	}
+}
+
+// BodyPolicy defines how request body is handled for an HTTP method.
+type BodyPolicy int
+
+const (
+	// BodyAllow encodes and sends request body.
+	BodyAllow BodyPolicy = iota
+	// BodyWarn sends request body and logs a warning.
+	BodyWarn
+	// BodySkip doesn't encode request body, so request is sent without it.
+	BodySkip
+	// BodyReject fails request with ErrBodyNotAllowed.
+	BodyReject
+)
+
+// ErrBodyNotAllowed is returned when request has a body, but BodyReject policy is set for its method.
+var ErrBodyNotAllowed = errors.New("request body is not allowed for method")
+
+var defaultBodyPolicies = map[string]BodyPolicy{
+	http.MethodGet:     BodySkip,
+	http.MethodHead:    BodySkip,
+	http.MethodConnect: BodySkip,
+}
+
+// bodyPolicy returns body policy of method.
+func (o *Options) bodyPolicy(method string) BodyPolicy {
+	if policy, ok := o.BodyPolicies[method]; ok {
+		return policy
+	}
+	return defaultBodyPolicies[method]
+}
+
+// retry returns retry options, creates them if they aren't defined yet.
+func (o *Options) retry() *OptionRetry {
+	if o.Retry == nil {
+		o.Retry = &OptionRetry{}
+	}
+	return o.Retry
+}
+
+// WithBodyPolicy sets how request bodies of the given methods are handled. By default bodies of GET, HEAD
+// and CONNECT requests aren't encoded (BodySkip), other methods (including DELETE) send bodies as is (BodyAllow).
+//
+//	clientx.WithBodyPolicy(clientx.BodyAllow, http.MethodGet)    // e.g. search APIs accepting GET with body
+//	clientx.WithBodyPolicy(clientx.BodyWarn, http.MethodDelete)   // log a warning when DELETE is sent with body
+//	clientx.WithBodyPolicy(clientx.BodyReject, http.MethodDelete) // fail instead of sending DELETE with body
+func WithBodyPolicy(policy BodyPolicy, methods ...string) Option {
+	return func(o *Options) {
+		if o.BodyPolicies == nil {
+			o.BodyPolicies = make(map[string]BodyPolicy)
+		}
+		for _, method := range methods {
+			o.BodyPolicies[strings.ToUpper(method)] = policy
+		}
+	}
+}
+
+// WithQueryEncoder sets function which renders query param values of the same type as value,
+// applied by RequestBuilder.WithStructQueryParams. It's also applied to elements of slices.
+//
+//	clientx.WithQueryEncoder(0, func(v reflect.Value) string {
+//		return strconv.FormatInt(v.Int(), 16) // render ints in hex
+//	})
+func WithQueryEncoder(value any, f func(v reflect.Value) string) Option {
+	return func(o *Options) {
+		if o.QueryEncoders == nil {
+			o.QueryEncoders = make(map[reflect.Type]func(reflect.Value) string)
+		}
+		o.QueryEncoders[reflect.TypeOf(value)] = f
+	}
+}
+
+// WithQueryBoolFormat sets rendering of booleans in query params set by RequestBuilder.WithStructQueryParams,
+// e.g. ("1", "0") or ("yes", "no") instead of default ("true", "false").
+func WithQueryBoolFormat(trueValue, falseValue string) Option {
+	return WithQueryEncoder(false, func(v reflect.Value) string {
+		if v.Bool() {
+			return trueValue
+		}
+		return falseValue
+	})
+}
+
+// WithRateLimitKeyFunc enables separate rate limit buckets, e.g. per HTTP method or endpoint for APIs
+// with different quotas. f selects bucket key of each request (including retries), buckets are created lazily
+// with limits set by WithKeyRateLimit for the key or by WithRateLimit otherwise. Empty key selects the shared bucket.
+//
+//	clientx.WithRateLimitKeyFunc(func(req *http.Request) string {
+//		if req.Method == http.MethodGet {
+//			return "" // shared bucket
+//		}
+//		return "write"
+//	}),
+//	clientx.WithKeyRateLimit("write", 1, 1, time.Second),
+func WithRateLimitKeyFunc(f func(*http.Request) string) Option {
+	return func(o *Options) {
+		o.RateLimitKeyFunc = f
+	}
+}
+
+// WithPerHostRateLimit enables separate rate limit bucket for each host of requests (URL.Host, including port),
+// e.g. when requests go to several hosts (see WithNamedBaseURL) or failover ones. Buckets are created lazily
+// with the same limit and burst, WithKeyRateLimit overrides them for particular host.
+// It replaces function set by WithRateLimitKeyFunc.
+func WithPerHostRateLimit(limit, burst int, per time.Duration) Option {
+	return func(o *Options) {
+		WithRateLimit(limit, burst, per)(o)
+		o.RateLimitKeyFunc = hostRateLimitKey
+	}
+}
+
+func hostRateLimitKey(req *http.Request) string {
+	return req.URL.Host
+}
+
+// WithTagRateLimit enables separate rate limit bucket for each value of request tag (see WithRequestTag),
+// e.g. per tier of API plan. Requests without the tag use the shared bucket, limits of particular buckets
+// are set by WithKeyRateLimit. It replaces function set by WithRateLimitKeyFunc.
+func WithTagRateLimit(tag string) Option {
+	return func(o *Options) {
+		o.RateLimitKeyFunc = func(req *http.Request) string {
+			return RequestTags(req)[tag]
+		}
+	}
+}
+
+// WithKeyRateLimit sets limit and burst of rate limit bucket selected by RateLimitKeyFunc.
+func WithKeyRateLimit(key string, limit, burst int, per time.Duration) Option {
+	return func(o *Options) {
+		if o.KeyRateLimits == nil {
+			o.KeyRateLimits = make(map[string]*OptionRateLimit)
+		}
+		o.KeyRateLimits[key] = &OptionRateLimit{
+			Limit: limit,
+			Burst: burst,
+			Per:   per,
 		}
 	}
 }
@@ -141,12 +557,175 @@ func WithRateLimit(limit int, burst int, per time.Duration) Option {
 	}
 }
 
+// WithRateLimitParseFn sets custom function that parses rate limits from HTTP response headers or body.
+// After each response the rate limiter (bucket) of request adapts to them: remaining requests are spread
+// evenly until resetAt, then original limit and burst are restored. Parsing errors are logged and ignored,
+// body is nil for responses streamed by DoStream.
+func WithRateLimitParseFn(f func(resp *http.Response, body []byte) (limit int, remaining int, resetAt time.Time, err error)) Option {
+	return func(o *Options) {
+		o.RateLimitParseFn = f
+	}
+}
+
+// WithMaxQueueDepth limits how many requests can wait for a rate limit token simultaneously.
+// Requests exceeding the limit are rejected immediately with ErrRateLimitExceeded.
+func WithMaxQueueDepth(n int) Option {
+	return func(o *Options) {
+		o.MaxQueueDepth = n
+	}
+}
+
+// WithMaxConcurrency limits number of requests performed simultaneously (including asynchronous ones).
+// Requests exceeding the limit wait for a free slot.
+func WithMaxConcurrency(n int) Option {
+	return func(o *Options) {
+		o.MaxConcurrency = n
+	}
+}
+
+// WithOperationTimeout limits duration of the whole operation: waiting for a rate limit token, all retry
+// attempts with backoff waits and decoding of the response. Unlike http.Client.Timeout, which applies to each
+// attempt separately, operation fails with context.DeadlineExceeded once d elapses since the call.
+func WithOperationTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.OperationTimeout = d
+	}
+}
+
+// WithRateLimitWaitTimeout sets client-wide maximum time request may wait for a rate limit token.
+// If the token isn't obtained within d, request fails with ErrRateLimitExceeded.
+// It's a backstop for callers that don't set context deadline.
+func WithRateLimitWaitTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.RateLimitWaitTimeout = d
+	}
+}
+
+// WithRequestID enables generating request ID for each request. The ID is set to header (X-Request-ID by default)
+// and stays the same across retries. If f generator isn't provided NewUUID will be used.
+// The ID from ContextWithRequestID takes precedence over generated one.
+func WithRequestID(header string, f func() string) Option {
+	return func(o *Options) {
+		if header == "" {
+			header = DefaultRequestIDHeader
+		}
+		if f == nil {
+			f = NewUUID // uses as default
+		}
+		o.RequestID = &OptionRequestID{
+			Header:   header,
+			Generate: f,
+		}
+	}
+}
+
+// WithHTTPCache enables caching of GET responses in cache (in-memory cache if nil).
+// Honors Cache-Control (max-age, no-store, no-cache) and Expires headers, stale responses are revalidated
+// with conditional requests using ETag and Last-Modified validators. The client acts as a private cache,
+// so responses marked as "private" are cached too.
+func WithHTTPCache(cache Cache) Option {
+	return func(o *Options) {
+		if cache == nil {
+			cache = NewMemoryCache()
+		}
+		o.Cache = cache
+	}
+}
+
+// WithCacheKeyFunc sets custom function to compute cache key of request (see WithHTTPCache).
+//
+// SECURITY: the default key (DefaultCacheKey) contains only method and URL. If responses depend on
+// the caller (Authorization, tenant headers, etc.), include these values in the key (preferably hashed),
+// otherwise one caller may be served a response cached for another.
+func WithCacheKeyFunc(f func(*http.Request) string) Option {
+	return func(o *Options) {
+		o.CacheKeyFunc = f
+	}
+}
+
+// WithDefaultRequestOptions sets request options that are applied to every request before
+// per-request options, e.g. default Accept header or request signing.
+func WithDefaultRequestOptions(opts ...RequestOption) Option {
+	return func(o *Options) {
+		o.RequestOptions = append(o.RequestOptions, opts...)
+	}
+}
+
 // WithHeaders sets global headers. Overwrites previously defined header set.
+// Headers are copied, so further modifications of headers map don't affect the API.
+// Keys are canonicalized (see http.CanonicalHeaderKey), values of keys differing only in case are merged.
 func WithHeaders(headers map[string][]string) Option {
 	return func(o *Options) {
-		if len(o.Headers) == 0 {
-			o.Headers = make(http.Header)
-		}
-		o.Headers = headers
+		o.Headers = canonicalHeader(headers)
+	}
+}
+
+// baseURL returns base URL of named endpoint, or BaseURL if name is empty.
+func (api *API) baseURL(name string) (string, error) {
+	if name == "" {
+		return api.options.BaseURL, nil
+	}
+	u, ok := api.options.Endpoints[name]
+	if !ok {
+		return "", fmt.Errorf("unknown endpoint %q", name)
+	}
+	return u, nil
+}
+
+// RateLimit returns limit (events per second) and burst currently enforced by the rate limiter.
+// They may differ from configured ones after adaptation to server rate limit headers.
+// Returns rate.Inf if rate limiting isn't configured. Reports false if custom limiter (see WithLimiter)
+// doesn't implement LimitReporter.
+func (api *API) RateLimit() (rate.Limit, int, bool) {
+	r, ok := limitReporter(api.limiter)
+	if !ok {
+		return 0, 0, false
+	}
+	return r.Limit(), r.Burst(), true
+}
+
+// WaitUntilAllowed blocks until n tokens of the rate limiter are available, e.g. to pace a bulk job
+// of n requests, so they don't block mid-flight. Tokens aren't consumed, requests consume them as usual
+// (concurrent requests may take them first). Buckets selected by RateLimitKeyFunc aren't checked.
+// Returns error wrapping ErrRateLimitExceeded if n exceeds burst, or if tokens can't be available before
+// context deadline (ErrRateLimitDeadline). Custom limiter (see WithLimiter) has to implement AvailableLimiter,
+// otherwise ErrLimiterUnsupported is returned.
+func (api *API) WaitUntilAllowed(ctx context.Context, n int) error {
+	a, ok := api.limiter.(AvailableLimiter)
+	if !ok {
+		return unsupportedAvailableLimiter(api.limiter)
 	}
+	return a.WaitAvailable(ctx, n)
 }
+
+// HTTPClient returns *http.Client used to perform requests, with transport options applied
+// (e.g. to reuse configured transport for a websocket upgrade). The client is shared by all requests
+// of the API, so it must be treated as read-only.
+func (api *API) HTTPClient() *http.Client {
+	return api.httpClient
+}
+
+// operationContext derives context with OperationTimeout deadline (if set).
+func (api *API) operationContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if api.options.OperationTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, api.options.OperationTimeout)
+}
+
+// logger returns configured logger or logger which discards all records.
+func (api *API) logger() *slog.Logger {
+	if api.options.Logger != nil {
+		return api.options.Logger
+	}
+	return discardLogger
+}
+
+var discardLogger = slog.New(discardHandler{})
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
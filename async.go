@@ -0,0 +1,90 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// RequestResult is a result of asynchronous request.
+type RequestResult[Resp any] struct {
+	Response *http.Response
+	Decoded  *Resp
+	Err      error
+}
+
+// DoAsync executes request in a separate goroutine and decodes response into Resp object (see DoWithDecode).
+// The result is delivered into returned channel, which is closed afterwards. Rate limiting and retries are applied
+// as usual. If WithMaxConcurrency is set, DoAsync blocks until a concurrency slot is available, so asynchronous
+// submission never spawns more goroutines than the limit allows.
+func (rb *RequestBuilder[Req, Resp]) DoAsync(ctx context.Context, enc ...EncoderDecoder) <-chan RequestResult[Resp] {
+	ch := make(chan RequestResult[Resp], 1)
+	e, err := rb.client.api.selectEncoderDecoder(enc)
+	if err != nil {
+		rb.finish(nil, err, RetryStats{})
+		ch <- RequestResult[Resp]{Err: err}
+		close(ch)
+		return ch
+	}
+
+	release, err := rb.client.api.acquire(ctx)
+	if err != nil {
+		rb.finish(nil, err, RetryStats{})
+		ch <- RequestResult[Resp]{Err: err}
+		close(ch)
+		return ch
+	}
+
+	go func() {
+		defer close(ch)
+		defer release()
+		resp, decoded, err := rb.client.do(withAcquired(ctx), rb, true, e)
+		ch <- RequestResult[Resp]{Response: resp, Decoded: decoded, Err: err}
+	}()
+	return ch
+}
+
+// Batch executes requests concurrently (see DoAsync) and returns their results in order of builders
+// and a single error joining (errors.Join) errors of failed requests, or nil if all of them succeeded.
+// Each joined error is prefixed with index of the request and wraps its original error, so typed errors
+// are still matched by errors.Is and errors.As.
+func Batch[Req any, Resp any](ctx context.Context, builders []*RequestBuilder[Req, Resp], enc ...EncoderDecoder) ([]RequestResult[Resp], error) {
+	chs := make([]<-chan RequestResult[Resp], len(builders))
+	for i, rb := range builders {
+		chs[i] = rb.DoAsync(ctx, enc...)
+	}
+
+	results := make([]RequestResult[Resp], len(builders))
+	var errs []error
+	for i, ch := range chs {
+		results[i] = <-ch
+		if err := results[i].Err; err != nil {
+			errs = append(errs, fmt.Errorf("request %d: %w", i, err))
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+type acquiredKey struct{}
+
+// withAcquired marks ctx as holding a concurrency slot, so it isn't acquired twice.
+func withAcquired(ctx context.Context) context.Context {
+	return context.WithValue(ctx, acquiredKey{}, true)
+}
+
+// acquire obtains a concurrency slot if WithMaxConcurrency is set. Returns function to release the slot.
+func (api *API) acquire(ctx context.Context) (func(), error) {
+	if api.sem == nil || ctx.Value(acquiredKey{}) != nil {
+		return func() {}, nil
+	}
+	select {
+	case api.sem <- struct{}{}:
+		return func() { <-api.sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
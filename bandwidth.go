@@ -0,0 +1,55 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// WithBandwidthLimit limits throughput of request and response bodies to bytesPerSec per request.
+// Unlike WithRateLimit, which limits number of requests, it limits number of transferred bytes.
+// Throttling respects request context cancellation.
+func WithBandwidthLimit(bytesPerSec int) Option {
+	return func(o *Options) {
+		o.BandwidthLimit = bytesPerSec
+	}
+}
+
+// throttledReader is a reader which reads no faster than the limiter allows.
+type throttledReader struct {
+	ctx     context.Context
+	r       io.ReadCloser
+	limiter *rate.Limiter
+}
+
+func newThrottledReader(ctx context.Context, r io.ReadCloser, bytesPerSec int) io.ReadCloser {
+	limiter := rate.NewLimiter(rate.Limit(bytesPerSec), bytesPerSec)
+	limiter.AllowN(time.Now(), bytesPerSec) // start with empty bucket, so the first burst is throttled too
+	return &throttledReader{
+		ctx:     ctx,
+		r:       r,
+		limiter: limiter,
+	}
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if burst := r.limiter.Burst(); len(p) > burst {
+		p = p[:burst] // WaitN fails if n exceeds burst
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if werr := r.limiter.WaitN(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func (r *throttledReader) Close() error {
+	return r.r.Close()
+}
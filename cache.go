@@ -0,0 +1,268 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache is a general interface for HTTP response cache storage.
+type Cache interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, resp *CachedResponse)
+	Delete(key string)
+}
+
+// CachedResponse is a stored response with freshness information.
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	// Body is a raw (not decompressed) response body.
+	Body []byte
+	// ExpiresAt is a time after which response is stale and has to be revalidated.
+	ExpiresAt time.Time
+	// MustRevalidate is true when response has to be revalidated before each use (no-cache).
+	MustRevalidate bool
+	// Vary contains values of request headers listed in Vary response header.
+	Vary http.Header
+}
+
+func (cr *CachedResponse) isFresh(now time.Time) bool {
+	return !cr.MustRevalidate && now.Before(cr.ExpiresAt)
+}
+
+func (cr *CachedResponse) hasValidators() bool {
+	return cr.Header.Get("ETag") != "" || cr.Header.Get("Last-Modified") != ""
+}
+
+func (cr *CachedResponse) matchVary(req *http.Request) bool {
+	for key, values := range cr.Vary {
+		if strings.Join(req.Header.Values(key), ",") != strings.Join(values, ",") {
+			return false
+		}
+	}
+	return true
+}
+
+func (cr *CachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(cr.StatusCode) + " " + http.StatusText(cr.StatusCode),
+		StatusCode:    cr.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        cr.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(cr.Body)),
+		ContentLength: int64(len(cr.Body)),
+		Request:       req,
+	}
+}
+
+// memoryCache is a thread-safe in-memory Cache implementation.
+type memoryCache struct {
+	mu      sync.RWMutex
+	entries map[string]*CachedResponse
+}
+
+var _ Cache = (*memoryCache)(nil)
+
+// NewMemoryCache returns thread-safe in-memory Cache. Entries are kept until they are replaced,
+// so it's suitable for a bounded set of resources.
+func NewMemoryCache() Cache {
+	return &memoryCache{
+		entries: make(map[string]*CachedResponse),
+	}
+}
+
+func (c *memoryCache) Get(key string) (*CachedResponse, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	resp, ok := c.entries[key]
+	return resp, ok
+}
+
+func (c *memoryCache) Set(key string, resp *CachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = resp
+}
+
+func (c *memoryCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// cacheableStatus contains status codes that are cacheable by default (RFC 7231, section 6.1).
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+}
+
+// cachedExecute serves GET requests from the cache when stored response is fresh, revalidates stale
+// responses with conditional request (If-None-Match, If-Modified-Since) and stores cacheable responses.
+func (api *API) cachedExecute(req *http.Request, execute func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	cache := api.options.Cache
+	if cache == nil || req.Method != http.MethodGet {
+		return execute(req)
+	}
+	reqDirectives := parseCacheControl(req.Header.Values("Cache-Control"))
+	if _, ok := reqDirectives["no-store"]; ok {
+		return execute(req)
+	}
+
+	keyFn := api.options.CacheKeyFunc
+	if keyFn == nil {
+		keyFn = DefaultCacheKey
+	}
+	key := keyFn(req)
+	now := time.Now()
+	stored, ok := cache.Get(key)
+	if ok && !stored.matchVary(req) {
+		ok = false
+	}
+	if ok {
+		_, noCache := reqDirectives["no-cache"]
+		if stored.isFresh(now) && !noCache {
+			return stored.response(req), nil
+		}
+		if stored.hasValidators() {
+			req = req.Clone(req.Context())
+			if etag := stored.Header.Get("ETag"); etag != "" {
+				req.Header.Set("If-None-Match", etag)
+			}
+			if lastModified := stored.Header.Get("Last-Modified"); lastModified != "" {
+				req.Header.Set("If-Modified-Since", lastModified)
+			}
+		}
+	}
+
+	resp, err := execute(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		// Update stored headers with the new ones (RFC 7234, section 4.3.4)
+		header := stored.Header.Clone()
+		for key, values := range resp.Header {
+			header[key] = values
+		}
+		updated := newCachedResponse(req, stored.StatusCode, header, stored.Body, now)
+		if updated == nil {
+			cache.Delete(key)
+			return stored.response(req), nil
+		}
+		cache.Set(key, updated)
+		return updated.response(req), nil
+	}
+
+	if !cacheableStatus[resp.StatusCode] {
+		return resp, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	if entry := newCachedResponse(req, resp.StatusCode, resp.Header, body, now); entry != nil {
+		cache.Set(key, entry)
+	} else {
+		cache.Delete(key)
+	}
+	return resp, nil
+}
+
+// newCachedResponse builds cache entry according to Cache-Control and Expires headers.
+// Returns nil if response mustn't be stored. The client is considered as a private cache,
+// so responses with "private" directive are stored.
+func newCachedResponse(req *http.Request, statusCode int, header http.Header, body []byte, now time.Time) *CachedResponse {
+	directives := parseCacheControl(header.Values("Cache-Control"))
+	if _, ok := directives["no-store"]; ok {
+		return nil
+	}
+
+	entry := &CachedResponse{
+		StatusCode: statusCode,
+		Header:     header.Clone(),
+		Body:       body,
+	}
+	for _, key := range splitHeaderList(header.Values("Vary")) {
+		if key == "*" {
+			return nil
+		}
+		if entry.Vary == nil {
+			entry.Vary = make(http.Header)
+		}
+		entry.Vary[http.CanonicalHeaderKey(key)] = req.Header.Values(key)
+	}
+
+	if _, ok := directives["no-cache"]; ok {
+		entry.MustRevalidate = true
+	}
+	if maxAge, ok := directives["max-age"]; ok {
+		secs, err := strconv.Atoi(maxAge)
+		if err != nil {
+			return nil
+		}
+		age, _ := strconv.Atoi(header.Get("Age"))
+		entry.ExpiresAt = now.Add(time.Duration(secs-age) * time.Second)
+	} else if expires := header.Get("Expires"); expires != "" {
+		expiresAt, err := http.ParseTime(expires)
+		if err != nil {
+			// Invalid Expires means already expired (RFC 7234, section 5.3)
+			expiresAt = time.Time{}
+		}
+		if date, err := http.ParseTime(header.Get("Date")); err == nil {
+			// Compensate clock skew between client and server
+			expiresAt = now.Add(expiresAt.Sub(date))
+		}
+		entry.ExpiresAt = expiresAt
+	}
+
+	if !entry.isFresh(now) && !entry.hasValidators() {
+		// Can be neither served nor revalidated
+		return nil
+	}
+	return entry
+}
+
+// DefaultCacheKey returns cache key built from method and normalized URL: lowercased scheme and host,
+// query parameters sorted by key, so parameters order doesn't produce different keys.
+// Note that the key doesn't include any headers, so responses to authorized requests
+// are shared between all callers of the API, use WithCacheKeyFunc to include them.
+func DefaultCacheKey(req *http.Request) string {
+	u := *req.URL
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.RawQuery = u.Query().Encode() // Encode sorts by key
+	u.Fragment = ""
+	return req.Method + " " + u.String()
+}
+
+// parseCacheControl parses Cache-Control directives into map, e.g. "max-age=60, no-cache"
+// into {"max-age": "60", "no-cache": ""}.
+func parseCacheControl(values []string) map[string]string {
+	directives := make(map[string]string)
+	for _, directive := range splitHeaderList(values) {
+		name, value, _ := strings.Cut(directive, "=")
+		directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return directives
+}
@@ -0,0 +1,308 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testETag         = `"v1"`
+	testLastModified = "Mon, 02 Jan 2006 15:04:05 GMT"
+)
+
+func TestHTTPCache(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	past := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	now := time.Now().UTC().Format(http.TimeFormat)
+	tests := []struct {
+		name   string
+		method string
+		status int
+		// header is sent in responses with status.
+		header http.Header
+		// first and second are headers of the first and the second requests.
+		first, second   map[string][]string
+		wantHits        int64
+		wantBody        string
+		wantConditional http.Header
+	}{
+		{
+			name:     "fresh by max-age",
+			header:   http.Header{"Cache-Control": {"max-age=60"}},
+			wantHits: 1,
+			wantBody: "v1",
+		},
+		{
+			name:     "stale by Age",
+			header:   http.Header{"Cache-Control": {"max-age=60"}, "Age": {"120"}},
+			wantHits: 2,
+			wantBody: "v2",
+		},
+		{
+			name:     "invalid max-age",
+			header:   http.Header{"Cache-Control": {"max-age=abc"}},
+			wantHits: 2,
+			wantBody: "v2",
+		},
+		{
+			name:     "fresh by Expires",
+			header:   http.Header{"Expires": {future}, "Date": {now}},
+			wantHits: 1,
+			wantBody: "v1",
+		},
+		{
+			name:     "max-age takes precedence over Expires",
+			header:   http.Header{"Cache-Control": {"max-age=60"}, "Expires": {past}},
+			wantHits: 1,
+			wantBody: "v1",
+		},
+		{
+			name:     "stale by Expires",
+			header:   http.Header{"Expires": {past}, "Date": {now}},
+			wantHits: 2,
+			wantBody: "v2",
+		},
+		{
+			name:     "invalid Expires",
+			header:   http.Header{"Expires": {"0"}},
+			wantHits: 2,
+			wantBody: "v2",
+		},
+		{
+			name:     "no-store",
+			header:   http.Header{"Cache-Control": {"no-store, max-age=60"}},
+			wantHits: 2,
+			wantBody: "v2",
+		},
+		{
+			name:            "no-cache is revalidated with ETag",
+			header:          http.Header{"Cache-Control": {"no-cache, max-age=60"}, "ETag": {testETag}},
+			wantHits:        2,
+			wantBody:        "v1",
+			wantConditional: http.Header{"If-None-Match": {testETag}},
+		},
+		{
+			name:            "stale is revalidated with Last-Modified",
+			header:          http.Header{"Cache-Control": {"max-age=0"}, "Last-Modified": {testLastModified}},
+			wantHits:        2,
+			wantBody:        "v1",
+			wantConditional: http.Header{"If-Modified-Since": {testLastModified}},
+		},
+		{
+			name:            "stale is revalidated with both validators",
+			header:          http.Header{"Expires": {past}, "ETag": {testETag}, "Last-Modified": {testLastModified}},
+			wantHits:        2,
+			wantBody:        "v1",
+			wantConditional: http.Header{"If-None-Match": {testETag}, "If-Modified-Since": {testLastModified}},
+		},
+		{
+			name:            "request no-cache revalidates fresh response",
+			header:          http.Header{"Cache-Control": {"max-age=60"}, "ETag": {testETag}},
+			second:          map[string][]string{"Cache-Control": {"no-cache"}},
+			wantHits:        2,
+			wantBody:        "v1",
+			wantConditional: http.Header{"If-None-Match": {testETag}},
+		},
+		{
+			name:     "request no-store bypasses cache",
+			header:   http.Header{"Cache-Control": {"max-age=60"}},
+			second:   map[string][]string{"Cache-Control": {"no-store"}},
+			wantHits: 2,
+			wantBody: "v2",
+		},
+		{
+			name:     "Vary matches",
+			header:   http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"Accept-Language"}},
+			first:    map[string][]string{"Accept-Language": {"en"}},
+			second:   map[string][]string{"Accept-Language": {"en"}},
+			wantHits: 1,
+			wantBody: "v1",
+		},
+		{
+			name:     "Vary doesn't match",
+			header:   http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"Accept-Language"}},
+			first:    map[string][]string{"Accept-Language": {"en"}},
+			second:   map[string][]string{"Accept-Language": {"de"}},
+			wantHits: 2,
+			wantBody: "v2",
+		},
+		{
+			name:     "Vary *",
+			header:   http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"*"}},
+			wantHits: 2,
+			wantBody: "v2",
+		},
+		{
+			name:     "cacheable status",
+			status:   http.StatusNotFound,
+			header:   http.Header{"Cache-Control": {"max-age=60"}},
+			wantHits: 1,
+			wantBody: "v1",
+		},
+		{
+			name:     "not cacheable status",
+			status:   http.StatusAccepted,
+			header:   http.Header{"Cache-Control": {"max-age=60"}},
+			wantHits: 2,
+			wantBody: "v2",
+		},
+		{
+			name:     "not GET",
+			method:   http.MethodPut,
+			header:   http.Header{"Cache-Control": {"max-age=60"}},
+			wantHits: 2,
+			wantBody: "v2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				hits        int64
+				conditional http.Header
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt64(&hits, 1)
+				for key, vals := range tt.header {
+					w.Header()[key] = vals
+				}
+				if n > 1 {
+					conditional = make(http.Header)
+					for _, key := range []string{"If-None-Match", "If-Modified-Since"} {
+						if v := r.Header.Get(key); v != "" {
+							conditional.Set(key, v)
+						}
+					}
+					if len(conditional) > 0 {
+						w.WriteHeader(http.StatusNotModified)
+						return
+					}
+				}
+				status := tt.status
+				if status == 0 {
+					status = http.StatusOK
+				}
+				w.WriteHeader(status)
+				fmt.Fprintf(w, "v%d", n)
+			}))
+			defer srv.Close()
+
+			api := NewAPI(WithBaseURL(srv.URL), WithHTTPCache(nil))
+			do := func(header map[string][]string) string {
+				rb := NewRequestBuilder[Empty, Empty](api)
+				if tt.method == http.MethodPut {
+					rb = rb.Put("/resource", nil, WithRequestHeaders(header))
+				} else {
+					rb = rb.Get("/resource", WithRequestHeaders(header))
+				}
+				resp, err := rb.Do(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer resp.Body.Close()
+				b, err := io.ReadAll(resp.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return string(b)
+			}
+
+			if got := do(tt.first); got != "v1" {
+				t.Fatalf("first request: got body %q, want %q", got, "v1")
+			}
+			if got := do(tt.second); got != tt.wantBody {
+				t.Errorf("second request: got body %q, want %q", got, tt.wantBody)
+			}
+			if n := atomic.LoadInt64(&hits); n != tt.wantHits {
+				t.Errorf("server got %d requests, want %d", n, tt.wantHits)
+			}
+			if len(conditional) != len(tt.wantConditional) {
+				t.Fatalf("got conditional headers %v, want %v", conditional, tt.wantConditional)
+			}
+			for key := range tt.wantConditional {
+				if got, want := conditional.Get(key), tt.wantConditional.Get(key); got != want {
+					t.Errorf("got %s %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPCacheNotModifiedMergesHeaders(t *testing.T) {
+	var hits int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&hits, 1) == 1 {
+			w.Header().Set("Cache-Control", "max-age=0")
+			w.Header().Set("ETag", testETag)
+			w.Header().Set("X-Version", "1")
+			w.Header().Set("X-Kept", "yes")
+			io.WriteString(w, "body")
+			return
+		}
+		if r.Header.Get("If-None-Match") != testETag {
+			t.Errorf("got If-None-Match %q, want %q", r.Header.Get("If-None-Match"), testETag)
+		}
+		// Revalidated response becomes fresh, updated headers replace the stored ones
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("X-Version", "2")
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer srv.Close()
+
+	api := NewAPI(WithBaseURL(srv.URL), WithHTTPCache(nil))
+	for i := 0; i < 3; i++ {
+		resp, err := NewRequestBuilder[Empty, Empty](api).Get("/resource").Do(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(b) != "body" {
+			t.Errorf("request %d: got %d %q, want %d %q", i, resp.StatusCode, b, http.StatusOK, "body")
+		}
+		if i == 0 {
+			continue
+		}
+		if got := resp.Header.Get("X-Version"); got != "2" {
+			t.Errorf("request %d: got X-Version %q, want updated %q", i, got, "2")
+		}
+		if got := resp.Header.Get("X-Kept"); got != "yes" {
+			t.Errorf("request %d: got X-Kept %q, want stored %q", i, got, "yes")
+		}
+	}
+	// The third request is served from cache, since revalidation made response fresh
+	if n := atomic.LoadInt64(&hits); n != 2 {
+		t.Errorf("server got %d requests, want 2", n)
+	}
+}
+
+func TestDefaultCacheKey(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		same bool
+	}{
+		{"query order", "https://example.com/items?b=2&a=1", "https://example.com/items?a=1&b=2", true},
+		{"host case", "https://EXAMPLE.com/items", "https://example.com/items", true},
+		{"fragment", "https://example.com/items#top", "https://example.com/items", true},
+		{"different query", "https://example.com/items?a=1", "https://example.com/items?a=2", false},
+		{"different path", "https://example.com/items", "https://example.com/users", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := http.NewRequest(http.MethodGet, tt.a, nil)
+			b, _ := http.NewRequest(http.MethodGet, tt.b, nil)
+			if same := DefaultCacheKey(a) == DefaultCacheKey(b); same != tt.same {
+				t.Errorf("got keys %q and %q, want same %v", DefaultCacheKey(a), DefaultCacheKey(b), tt.same)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
+)
+
+// WithAcceptCharset sets Accept-Charset header of requests (unless it's set explicitly) and enables
+// transcoding of response bodies into UTF-8 according to charset parameter of response Content-Type,
+// e.g. "text/plain; charset=Shift_JIS", before they are decoded. XML bodies aren't transcoded,
+// because encoding/xml relies on charset declared in XML prolog.
+func WithAcceptCharset(charsets ...string) Option {
+	return func(o *Options) {
+		o.AcceptCharset = append(o.AcceptCharset, charsets...)
+	}
+}
+
+// transcodeReader returns reader of response body transcoded into UTF-8. Returns r as is
+// if response has no charset, its charset is UTF-8 or it's an XML document.
+func transcodeReader(resp *http.Response, r io.ReadCloser) (io.ReadCloser, error) {
+	if r == http.NoBody {
+		return r, nil
+	}
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return r, nil
+	}
+	if mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml") {
+		return r, nil
+	}
+	charset := params["charset"]
+	if charset == "" || strings.EqualFold(charset, "utf-8") || strings.EqualFold(charset, "utf8") {
+		return r, nil
+	}
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported response charset %q: %w", charset, err)
+	}
+	return readCloser{transform.NewReader(r, enc.NewDecoder()), r}, nil
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func encodeCharset(t *testing.T, enc *charmap.Charmap, s string) string {
+	t.Helper()
+	b, err := enc.NewEncoder().String(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestAcceptCharsetDecode(t *testing.T) {
+	type greeting struct {
+		Text string `json:"text"`
+	}
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{
+			name:        "windows-1251",
+			contentType: "application/json; charset=windows-1251",
+			body:        encodeCharset(t, charmap.Windows1251, `{"text":"Привіт, світ"}`),
+			want:        "Привіт, світ",
+		},
+		{
+			name:        "ISO-8859-1",
+			contentType: "application/json; charset=ISO-8859-1",
+			body:        encodeCharset(t, charmap.ISO8859_1, `{"text":"Grüße, café"}`),
+			want:        "Grüße, café",
+		},
+		{
+			name:        "charset label is case-insensitive",
+			contentType: "application/json; charset=Windows-1251",
+			body:        encodeCharset(t, charmap.Windows1251, `{"text":"Привіт"}`),
+			want:        "Привіт",
+		},
+		{
+			name:        "UTF-8",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"text":"Привіт"}`,
+			want:        "Привіт",
+		},
+		{
+			name:        "without charset",
+			contentType: "application/json",
+			body:        `{"text":"Привіт"}`,
+			want:        "Привіт",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var acceptCharset string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				acceptCharset = r.Header.Get("Accept-Charset")
+				w.Header().Set("Content-Type", tt.contentType)
+				io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+
+			api := NewAPI(WithBaseURL(srv.URL), WithAcceptCharset("utf-8", "windows-1251"))
+			got, err := NewRequestBuilder[Empty, greeting](api).Get("/greeting").DoWithDecode(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Text != tt.want {
+				t.Errorf("got text %q, want %q", got.Text, tt.want)
+			}
+			if acceptCharset != "utf-8, windows-1251" {
+				t.Errorf("got Accept-Charset %q, want %q", acceptCharset, "utf-8, windows-1251")
+			}
+		})
+	}
+}
+
+func TestAcceptCharsetXMLIsNotTranscoded(t *testing.T) {
+	body := encodeCharset(t, charmap.Windows1251, "<greeting>Привіт</greeting>")
+	resp := newTestResponse(http.MethodGet, http.StatusOK, http.Header{"Content-Type": {"text/xml; charset=windows-1251"}}, body)
+	r, err := transcodeReader(resp, io.NopCloser(strings.NewReader(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(r)
+	if string(got) != body {
+		t.Errorf("got %q, want body as is, since encoding/xml handles charset itself", got)
+	}
+}
+
+func TestAcceptCharsetStream(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+		wantErr     string
+	}{
+		{
+			name:        "known charset",
+			contentType: "text/plain; charset=windows-1251",
+			body:        encodeCharset(t, charmap.Windows1251, "рядок 1\nрядок 2\n"),
+			want:        "рядок 1\nрядок 2\n",
+		},
+		{
+			name:        "unknown charset",
+			contentType: "text/plain; charset=x-unknown-charset",
+			body:        "line",
+			wantErr:     `unsupported response charset "x-unknown-charset"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+
+			api := NewAPI(WithBaseURL(srv.URL), WithAcceptCharset("utf-8"))
+			_, reader, err := NewRequestBuilder[Empty, Empty](api).Get("/lines").DoStream(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reader.Close()
+			got, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -7,162 +7,524 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"os"
+	"strings"
 	"time"
 )
 
 type client[Req any, Resp any] struct {
 	api           *API
-	afterResponse []func(resp *http.Response, respBody []byte) error
+	beforeRequest []func(req *http.Request, body []byte) error
+	afterResponse []afterResponseHook
 }
 
-func (c *client[Req, Resp]) do(ctx context.Context, req *RequestBuilder[Req, Resp], decode bool, enc EncoderDecoder) (*http.Response, *Resp, error) {
-	// Wait for ratelimits. It is a blocking call.
-	if err := c.api.limiter.Wait(ctx); err != nil {
+type afterResponseHook struct {
+	f func(resp *http.Response, respBody []byte) error
+	// bestEffort hook errors are logged instead of aborting the request.
+	bestEffort bool
+}
+
+func (c *client[Req, Resp]) do(ctx context.Context, req *RequestBuilder[Req, Resp], decode bool, enc EncoderDecoder) (_ *http.Response, _ *Resp, err error) {
+	ctx, cancel := c.api.operationContext(ctx)
+	defer cancel()
+
+	var limit int64
+	if !decode {
+		limit = req.bodyLimit
+	}
+	httpResp, reader, stats, err := c.send(ctx, req, enc, limit, false)
+	defer func() {
+		req.finish(httpResp, err, stats)
+	}()
+	if err != nil {
 		return nil, nil, err
 	}
 
+	if req.errDecodeFn != nil {
+		ok, err := req.errDecodeFn(httpResp)
+		if ok {
+			return httpResp, nil, err
+		}
+	}
+	if err := c.api.checkStatus(httpResp, reader); err != nil {
+		return httpResp, nil, err
+	}
+	if err := req.checkContentType(httpResp, reader); err != nil {
+		return httpResp, nil, err
+	}
+
+	var decoded Resp
+	if decode && enc != nil {
+		if req.dataField != "" {
+			enc = &jsonDataFieldDecoder{EncoderDecoder: enc, field: req.dataField, envelope: req.envelope}
+		}
+		// Empty body (e.g. 204 or empty body labeled as gzip) is decoded into zero value
+		if reader != http.NoBody {
+			if err := decodeResponse(enc, reader, &decoded); err != nil {
+				return nil, nil, err
+			}
+		}
+		for _, after := range req.afterDecode {
+			if err := after(httpResp, &decoded); err != nil {
+				return nil, nil, fmt.Errorf("after decode exec failed: %w", err)
+			}
+		}
+	}
+
+	return httpResp, &decoded, nil
+}
+
+// send builds and executes request, executes after response hooks. Returns response, reader of decompressed
+// response body, which is limited to limit bytes if it's positive, and stats of retry sequence.
+// If stream is set and there are no AfterResponse hooks, body isn't buffered, reader wraps live response body.
+func (c *client[Req, Resp]) send(ctx context.Context, req *RequestBuilder[Req, Resp], enc EncoderDecoder, limit int64, stream bool) (_ *http.Response, _ io.ReadCloser, stats RetryStats, err error) {
+	release, err := c.api.acquire(ctx)
+	if err != nil {
+		return nil, nil, stats, err
+	}
+	defer release()
+
 	httpReq, err := c.buildRequest(ctx, req, enc)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, stats, err
+	}
+	req.lastRequest.Store(httpReq)
+	return c.sendRequest(ctx, req, httpReq, limit, stream)
+}
+
+// sendRequest executes built request (see send).
+func (c *client[Req, Resp]) sendRequest(ctx context.Context, req *RequestBuilder[Req, Resp], httpReq *http.Request, limit int64, stream bool) (_ *http.Response, _ io.ReadCloser, stats RetryStats, err error) {
+	var (
+		httpResp *http.Response
+		body     []byte
+		start    = time.Now()
+	)
+	if c.api.options.RequestLogger != nil {
+		defer func() {
+			c.api.logRequest(httpReq, httpResp, err, time.Since(start), stats.Attempts, len(body))
+		}()
+	}
+	if observers := c.api.options.Observers; len(observers) != 0 {
+		for _, o := range observers {
+			o.RequestStarted(httpReq)
+		}
+		defer func() {
+			duration := time.Since(start)
+			for _, o := range observers {
+				o.RequestFinished(httpReq, httpResp, err, duration, stats.Attempts)
+			}
+		}()
 	}
 
-	httpResp, err := c.executeRequest(ctx, httpReq, req)
+	httpResp, err = c.api.cachedExecute(httpReq, func(httpReq *http.Request) (*http.Response, error) {
+		resp, s, err := c.executeWithFailover(ctx, httpReq, req)
+		stats = s
+		return resp, err
+	})
+	if req.retryStats != nil {
+		*req.retryStats = stats
+	}
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, stats, err
+	}
+
+	if stream && len(c.afterResponse) == 0 {
+		// Hooks don't need body bytes, so there is no need to buffer it
+		reader, err := c.api.streamReader(httpResp, limit)
+		if err != nil {
+			return nil, nil, stats, err
+		}
+		c.api.adaptRateLimit(httpReq, httpResp, nil)
+		if len(c.api.options.AcceptCharset) != 0 {
+			transcoded, err := transcodeReader(httpResp, reader)
+			if err != nil {
+				reader.Close()
+				return nil, nil, stats, err
+			}
+			reader = transcoded
+		}
+		return httpResp, reader, stats, nil
 	}
 
-	nopCloseReader, body, err := responseReader(httpResp)
+	nopCloseReader, body, err := c.api.responseReader(httpResp, limit)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, stats, err
+	}
+	c.api.adaptRateLimit(httpReq, httpResp, body)
+	if len(c.api.options.AcceptCharset) != 0 {
+		if nopCloseReader, err = transcodeReader(httpResp, nopCloseReader); err != nil {
+			return nil, nil, stats, err
+		}
 	}
 
 	for _, after := range c.afterResponse {
-		if err := after(httpResp, body); err != nil {
-			return nil, nil, fmt.Errorf("after response exec failed: %w", err)
+		if err := after.f(httpResp, body); err != nil {
+			if after.bestEffort {
+				c.api.logger().Warn("best-effort after response hook failed", "error", err)
+				continue
+			}
+			return nil, nil, stats, fmt.Errorf("after response exec failed: %w", err)
 		}
 	}
 
-	if req.errDecodeFn != nil {
-		ok, err := req.errDecodeFn(httpResp)
-		if ok {
-			return httpResp, nil, err
+	return httpResp, nopCloseReader, stats, nil
+}
+
+// wait blocks until rate limiter (bucket) of req allows to perform it or RateLimitWaitTimeout is exceeded.
+func (c *client[Req, Resp]) wait(ctx context.Context, req *http.Request) error {
+	limiter := c.api.limiterFor(req)
+	if isFailFast(req.Context()) {
+		a, ok := allowLimiter(limiter)
+		if !ok {
+			return fmt.Errorf("%w: %T doesn't implement AllowLimiter", ErrLimiterUnsupported, limiter)
+		}
+		if !a.Allow() {
+			return ErrRateLimitExceeded
 		}
+		return nil
+	}
+	timeout := c.api.options.RateLimitWaitTimeout
+	if timeout <= 0 {
+		return limiter.Wait(ctx)
 	}
 
-	var decoded Resp
-	if decode && enc != nil {
-		if err := decodeResponse(enc, nopCloseReader, &decoded); err != nil {
-			return nil, nil, err
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := limiter.Wait(waitCtx); err != nil {
+		if ctx.Err() != nil {
+			// Parent context is done, not our timeout
+			return err
 		}
+		return ErrRateLimitExceeded
 	}
-
-	return httpResp, &decoded, nil
+	return nil
 }
 
 func (c *client[Req, Resp]) buildRequest(ctx context.Context, req *RequestBuilder[Req, Resp], enc EncoderDecoder) (*http.Request, error) {
-	u, err := c.buildRequestURL(req.resourcePath)
+	baseURL, err := c.api.baseURL(req.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u, err := c.buildRequestURL(baseURL, req.resourcePath)
 	if err != nil {
 		return nil, err
 	}
 
+	var requestID string
+	if c.api.options.RequestID != nil {
+		requestID = c.api.options.RequestID.requestID(ctx)
+		ctx = ContextWithRequestID(ctx, requestID)
+	}
+
 	httpReq, err := http.NewRequestWithContext(ctx, req.method, u.String(), nil)
 	if err != nil {
 		return nil, err
 	}
-	// If method is not GET, try to set payload body
-	if req.method != http.MethodGet && req.body != nil && enc != nil {
-		httpReq.Body, err = req.encodeRequestPayload(enc)
+	if len(c.api.options.Headers) != 0 {
+		// Global headers are shared between concurrent requests, so they
+		// must be copied before request options are applied.
+		httpReq.Header = c.api.options.Headers.Clone()
+	}
+	ct, _ := enc.(ContentTyper)
+	hasBody := (req.body != nil || req.jsonBody != nil) && enc != nil
+	if hasBody {
+		switch c.api.options.bodyPolicy(req.method) {
+		case BodySkip:
+			hasBody = false
+		case BodyWarn:
+			c.api.logger().Warn("request body is sent with method which usually doesn't have it", "method", req.method, "path", req.resourcePath)
+		case BodyReject:
+			return nil, fmt.Errorf("%w: %s", ErrBodyNotAllowed, req.method)
+		}
+	}
+	if hasBody {
+		b, contentType, err := req.encodeRequestPayload(enc)
 		if err != nil {
 			return nil, err
 		}
+		setRequestBody(httpReq, b)
+		if contentType == "" && ct != nil {
+			contentType = ct.ContentType()
+		}
+		if contentType != "" {
+			httpReq.Header.Set("Content-Type", contentType)
+		}
 	}
-	if len(c.api.options.Headers) != 0 {
-		httpReq.Header = c.api.options.Headers
+	if ct != nil && httpReq.Header.Get("Accept") == "" {
+		httpReq.Header.Set("Accept", ct.ContentType())
+	}
+	if len(c.api.options.AcceptCharset) != 0 && httpReq.Header.Get("Accept-Charset") == "" {
+		httpReq.Header.Set("Accept-Charset", strings.Join(c.api.options.AcceptCharset, ", "))
 	}
 
-	// Apply options to request
-	for _, opt := range req.requestOptions {
-		if err := opt(httpReq); err != nil {
-			return nil, err
+	// Apply options to request, default ones go first
+	for _, opts := range [][]RequestOption{c.api.options.RequestOptions, req.requestOptions} {
+		for _, opt := range opts {
+			if err := opt(httpReq); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if requestID != "" && httpReq.Header.Get(c.api.options.RequestID.Header) == "" {
+		httpReq.Header.Set(c.api.options.RequestID.Header, requestID)
+	}
+	for _, after := range req.afterBuild {
+		if err := after(httpReq); err != nil {
+			return nil, fmt.Errorf("after build exec failed: %w", err)
 		}
 	}
 
 	return httpReq, nil
 }
 
-func (c *client[Req, Resp]) executeRequest(ctx context.Context, httpReq *http.Request, req *RequestBuilder[Req, Resp]) (*http.Response, error) {
+// executeRequest performs request, retries it according to retry options. Returns stats of the retry sequence.
+func (c *client[Req, Resp]) executeRequest(ctx context.Context, httpReq *http.Request, req *RequestBuilder[Req, Resp]) (*http.Response, RetryStats, error) {
+	var sent bool // whether original body of httpReq was already sent
+	httpClient := req.httpClientFor(c.api)
 	do := func(c *client[Req, Resp], req *http.Request, reuse bool) (*http.Response, error) {
-		if reuse && req.Body != nil {
-			// Issue https://github.com/golang/go/issues/36095
-			var b bytes.Buffer
-			b.ReadFrom(req.Body)
-			req.Body = ioutil.NopCloser(&b)
+		if reuse && req.GetBody != nil {
+			if sent {
+				// Re-open body from source, so it isn't buffered into memory
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				cloneReq := req.Clone(req.Context())
+				cloneReq.Body = body
+				req = cloneReq
+			}
+			sent = true
+		}
+
+		if len(c.beforeRequest) != 0 {
+			body, err := requestBody(req)
+			if err != nil {
+				return nil, err
+			}
+			for _, before := range c.beforeRequest {
+				if err := before(req, body); err != nil {
+					return nil, fmt.Errorf("before request exec failed: %w", err)
+				}
+			}
+		}
+
+		bandwidth := c.api.options.BandwidthLimit
+		if bandwidth > 0 && req.Body != nil && req.Body != http.NoBody {
+			if req == httpReq {
+				req = req.Clone(req.Context()) // don't replace body of the original request
+			}
+			req.Body = newThrottledReader(req.Context(), req.Body, bandwidth)
+		}
+
+		// Wait for ratelimits before each attempt, so retries consume tokens too. It is a blocking call.
+		if err := c.wait(ctx, req); err != nil {
+			return nil, err
+		}
 
-			cloneReq := req.Clone(ctx)
-			cloneReq.Body = ioutil.NopCloser(bytes.NewReader(b.Bytes()))
-			req = cloneReq
+		if c.api.connStats != nil {
+			req = c.api.connStats.withClientTrace(req)
 		}
 
-		resp, err := c.api.httpClient.Do(req)
+		resp, err := c.api.doHedged(httpClient, req, c.wait)
 		if err != nil {
 			return nil, err
 		}
+		if bandwidth > 0 && resp.StatusCode != http.StatusSwitchingProtocols {
+			resp.Body = newThrottledReader(req.Context(), resp.Body, bandwidth)
+		}
 
 		if c.api.options.Debug {
-			reqb, err := httputil.DumpRequest(req, true)
-			if err != nil {
-				return nil, err
-			}
-			respb, err := httputil.DumpResponse(resp, true)
-			if err != nil {
+			if err := c.api.dump(req, resp); err != nil {
 				return nil, err
 			}
-			fmt.Fprintf(os.Stdout, "REQUEST:\n%s\nRESPONSE:\n%s\n", string(reqb), string(respb))
 		}
 		return resp, nil
 	}
-	if c.api.retry == nil {
+	retry := c.api.options.Retry
+	if retry == nil {
+		retry = new(OptionRetry) // custom Retrier is set without retry options
+	}
+	if c.api.newRetrier == nil || !retry.isRetryable(httpReq) {
 		// Do single request without using backoff retry mechanism
-		return do(c, httpReq, false)
+		resp, err := do(c, httpReq, false)
+		return resp, RetryStats{Attempts: 1}, err
 	}
 
-	for {
+	if httpReq.GetBody == nil && httpReq.Body != nil && httpReq.Body != http.NoBody {
+		// Body isn't replayable (issue https://github.com/golang/go/issues/36095), buffer it once
+		// for the whole retry sequence, each attempt reads it from the same immutable bytes
+		b, err := io.ReadAll(httpReq.Body)
+		httpReq.Body.Close()
+		if err != nil {
+			return nil, RetryStats{}, err
+		}
+		setRequestBody(httpReq, b)
+	}
+
+	// Retry state is isolated per request, so concurrent requests don't affect attempts of each other
+	retrier := c.api.newRetrier()
+	conditions := req.retryConditionsFor(retry.Conditions)
+	var stats RetryStats
+	for stats.Attempts = 1; ; stats.Attempts++ {
 		resp, err := do(c, httpReq, true)
 
-		var isMatchedCond bool
-		for _, cond := range c.api.options.Retry.Conditions {
-			if ok := cond(resp, err); ok {
-				isMatchedCond = true
+		hint, hasHint := retry.delayHint(c.api, resp)
+		isMatchedCond := hasHint
+		for _, cond := range conditions {
+			if isMatchedCond {
 				break
 			}
+			isMatchedCond = cond(resp, err)
 		}
 		if isMatchedCond {
 			// Get next duration interval, sleep and make another request
-			// till nextDuration != stopBackoff
-			nextDuration := c.api.retry.Next()
-			if nextDuration == stopBackoff {
-				c.api.retry.Reset()
-				return resp, err
+			// till nextDuration != StopBackoff
+			nextDuration := retrier.Next()
+			if nextDuration != StopBackoff {
+				// Server knows better when to come back
+				if hasHint {
+					nextDuration = hint
+				} else if retryAfter, ok := retry.retryAfter(resp); ok {
+					nextDuration = retryAfter
+				}
+			}
+			if nextDuration == StopBackoff {
+				stats.StopReason = RetryStopMaxAttempts
+			} else if !retry.allowRetry(ctx, resp, err, nextDuration) {
+				stats.StopReason = RetryStopGuard
+			}
+			if stats.StopReason != RetryNotStopped {
+				retrier.Reset()
+				return resp, stats, err
+			}
+			if resp != nil {
+				// Response of the last attempt won't be used, drain it so the connection can be reused
+				io.CopyN(io.Discard, resp.Body, maxDrainBytes)
+				resp.Body.Close()
+			}
+			waited, err := sleepContext(ctx, nextDuration)
+			stats.TotalWait += waited
+			if err != nil {
+				stats.StopReason = RetryStopContext
+				retrier.Reset()
+				return nil, stats, err
 			}
-			time.Sleep(nextDuration)
 			continue
 		}
 
 		// Break retries mechanism if conditions weren't matched
-		return resp, err
+		return resp, stats, err
+	}
+}
+
+// maxDrainBytes is a maximum size of unused response body read to reuse the connection,
+// larger bodies are just closed.
+const maxDrainBytes = 64 << 10
+
+// sleepContext pauses for d or until ctx is done. Returns duration of the pause and context error if it's done.
+func sleepContext(ctx context.Context, d time.Duration) (time.Duration, error) {
+	start := time.Now()
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return d, nil
+	case <-ctx.Done():
+		return time.Since(start), ctx.Err()
+	}
+}
+
+// dump writes request and response dumps into Logger with debug level if it's set, otherwise into os.Stdout.
+func (api *API) dump(req *http.Request, resp *http.Response) error {
+	logger := api.options.Logger
+	if logger != nil && !logger.Enabled(req.Context(), slog.LevelDebug) {
+		return nil // don't waste time on dumping
+	}
+
+	reqb, err := httputil.DumpRequest(req, true)
+	if err != nil {
+		return err
+	}
+	// Body of 101 Switching Protocols is the upgraded connection, reading it would block
+	respb, err := httputil.DumpResponse(resp, resp.StatusCode != http.StatusSwitchingProtocols)
+	if err != nil {
+		return err
+	}
+
+	id := RequestIDFromContext(req.Context())
+	if logger != nil {
+		attrs := []any{"request", string(reqb), "response", string(respb)}
+		if id != "" {
+			attrs = append(attrs, "request_id", id)
+		}
+		logger.DebugContext(req.Context(), "http dump", attrs...)
+		return nil
 	}
+	if id != "" {
+		fmt.Fprintf(os.Stdout, "REQUEST (id=%s):\n%s\nRESPONSE:\n%s\n", id, string(reqb), string(respb))
+	} else {
+		fmt.Fprintf(os.Stdout, "REQUEST:\n%s\nRESPONSE:\n%s\n", string(reqb), string(respb))
+	}
+	return nil
+}
+
+// setRequestBody sets replayable request body, so it can be safely read by hooks and reused by retries.
+func setRequestBody(req *http.Request, b []byte) {
+	req.ContentLength = int64(len(b))
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+}
+
+// requestBody returns request body bytes without consuming req.Body.
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody == nil {
+		// Body isn't replayable, buffer it and make it replayable
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		setRequestBody(req, b)
+		return b, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
 }
 
-func (c *client[Req, Resp]) buildRequestURL(resource string) (*url.URL, error) {
-	u, err := url.Parse(c.api.options.BaseURL)
+func (c *client[Req, Resp]) buildRequestURL(baseURL, resource string) (*url.URL, error) {
+	u, err := url.Parse(baseURL)
 	if err != nil {
 		return nil, err
 	}
-	u.Path = resource
+	if path, err := url.PathUnescape(resource); err == nil && path != resource {
+		// Resource is already escaped (e.g. path params containing '/'), keep its encoding
+		u.Path, u.RawPath = joinPath(u.Path, path), joinPath(u.EscapedPath(), resource)
+	} else {
+		u.Path = joinPath(u.Path, resource)
+		u.RawPath = ""
+	}
 	return u, nil
 }
+
+// joinPath appends resource path to base path (e.g. "/v1" and "/users" are joined into "/v1/users")
+// with a single slash between them. Trailing slash of resource is preserved.
+func joinPath(base, resource string) string {
+	if resource == "" {
+		return base
+	}
+	return strings.TrimRight(base, "/") + "/" + strings.TrimLeft(resource, "/")
+}
@@ -0,0 +1,386 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentDo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer srv.Close()
+
+	api := NewAPI(
+		WithBaseURL(srv.URL),
+		WithHeaders(map[string][]string{"X-Client": {"clientx"}}),
+	)
+	type resp struct {
+		ID int `json:"id"`
+	}
+
+	const n = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			decoded, err := NewRequestBuilder[Empty, resp](api).
+				Get("/items", WithRequestHeaders(map[string][]string{"X-Request": {"1"}})).
+				DoWithDecode(context.Background())
+			if err == nil && decoded.ID != 1 {
+				t.Errorf("got id %d, want 1", decoded.ID)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGlobalHeadersNotMutated(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	api := NewAPI(
+		WithBaseURL(srv.URL),
+		WithHeaders(map[string][]string{"X-Global": {"a"}}),
+	)
+
+	const n = 50
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			mutate := func(req *http.Request) error {
+				req.Header.Add("X-Global", strconv.Itoa(i))
+				req.Header.Set("X-Local", strconv.Itoa(i))
+				return nil
+			}
+			if _, err := NewRequestBuilder[Empty, Empty](api).Get("/", mutate).Do(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	want := http.Header{"X-Global": {"a"}}
+	if !reflect.DeepEqual(api.options.Headers, want) {
+		t.Fatalf("global headers are mutated: got %v, want %v", api.options.Headers, want)
+	}
+}
+
+func TestRetryStatePerRequest(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		attempts = make(map[string]int)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts[r.Header.Get("X-Request")]++
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	const maxAttempts = 3
+	api := NewAPI(
+		WithBaseURL(srv.URL),
+		WithRetry(maxAttempts, time.Millisecond, 5*time.Millisecond, nil, func(resp *http.Response, err error) bool {
+			return resp != nil && resp.StatusCode == http.StatusServiceUnavailable
+		}),
+	)
+
+	const n = 20
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var stats RetryStats
+			resp, err := NewRequestBuilder[Empty, Empty](api).
+				Get("/", WithRequestHeaders(map[string][]string{"X-Request": {strconv.Itoa(i)}})).
+				CaptureRetryStats(&stats).
+				Do(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+			if stats.Attempts != maxAttempts+1 || stats.StopReason != RetryStopMaxAttempts {
+				t.Errorf("request %d: got %d attempts (%s), want %d (%s)", i,
+					stats.Attempts, stats.StopReason, maxAttempts+1, RetryStopMaxAttempts)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for id, got := range attempts {
+		if got != maxAttempts+1 {
+			t.Errorf("request %s: server got %d attempts, want %d", id, got, maxAttempts+1)
+		}
+	}
+	if len(attempts) != n {
+		t.Errorf("server got %d requests, want %d", len(attempts), n)
+	}
+}
+
+func TestBuildRequestURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		baseURL  string
+		resource string
+		want     string
+	}{
+		{"base without path", "https://api.example.com", "/users", "https://api.example.com/users"},
+		{"base with trailing slash", "https://api.example.com/", "/users", "https://api.example.com/users"},
+		{"resource without leading slash", "https://api.example.com", "users", "https://api.example.com/users"},
+		{"base path", "https://api.example.com/v1", "/users", "https://api.example.com/v1/users"},
+		{"base path with trailing slash", "https://api.example.com/v1/", "/users", "https://api.example.com/v1/users"},
+		{"base path and resource without leading slash", "https://api.example.com/v1", "users", "https://api.example.com/v1/users"},
+		{"base path with trailing slash and resource without leading slash", "https://api.example.com/v1/", "users", "https://api.example.com/v1/users"},
+		{"resource trailing slash", "https://api.example.com/v1", "/users/", "https://api.example.com/v1/users/"},
+		{"empty resource", "https://api.example.com/v1/", "", "https://api.example.com/v1/"},
+		{"escaped resource", "https://api.example.com/v1", "/files/a%2Fb", "https://api.example.com/v1/files/a%2Fb"},
+	}
+	c := &client[Empty, Empty]{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := c.buildRequestURL(tt.baseURL, tt.resource)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := u.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBaseURLQuery(t *testing.T) {
+	type params struct {
+		X string `url:"x,omitempty"`
+	}
+	tests := []struct {
+		name   string
+		query  string
+		params params
+		want   url.Values
+	}{
+		{"base query only", "?apikey=abc", params{}, url.Values{"apikey": {"abc"}}},
+		{"request query only", "", params{X: "1"}, url.Values{"x": {"1"}}},
+		{"base and request query", "?apikey=abc", params{X: "1"}, url.Values{"apikey": {"abc"}, "x": {"1"}}},
+		{"overlapping keys", "?apikey=abc&x=0", params{X: "1"}, url.Values{"apikey": {"abc"}, "x": {"0", "1"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got url.Values
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.URL.Query()
+			}))
+			defer srv.Close()
+
+			api := NewAPI(WithBaseURL(srv.URL + "/v1/" + tt.query))
+			_, err := NewRequestBuilder[Empty, Empty](api).
+				Get("/users", WithRequestQueryParams("url", tt.params)).
+				Do(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got query %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFailoverBaseURLQuery(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer primary.Close()
+	var got url.Values
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Query()
+	}))
+	defer secondary.Close()
+
+	api := NewAPI(
+		WithBaseURL(primary.URL+"?apikey=abc"),
+		WithFailover(secondary.URL+"?key=def"),
+	)
+	type params struct {
+		X string `url:"x"`
+	}
+	_, err := NewRequestBuilder[Empty, Empty](api).
+		Get("/users", WithRequestQueryParams("url", params{X: "1"})).
+		Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (url.Values{"key": {"def"}, "x": {"1"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got query %v, want %v", got, want)
+	}
+}
+
+func TestRetryCancelDuringBackoff(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	api := NewAPI(
+		WithBaseURL(srv.URL),
+		WithRetry(5, time.Minute, time.Minute, ConstantBackoff, func(resp *http.Response, err error) bool {
+			return resp != nil && resp.StatusCode == http.StatusServiceUnavailable
+		}),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var stats RetryStats
+	start := time.Now()
+	_, err := NewRequestBuilder[Empty, Empty](api).Get("/").CaptureRetryStats(&stats).Do(ctx)
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	if stats.StopReason != RetryStopContext {
+		t.Errorf("got stop reason %s, want %s", stats.StopReason, RetryStopContext)
+	}
+	if elapsed > time.Second {
+		t.Errorf("request returned after %v, want prompt return on cancellation", elapsed)
+	}
+}
+
+func TestBodyPolicy(t *testing.T) {
+	type payload struct {
+		ID int `json:"id"`
+	}
+	tests := []struct {
+		name     string
+		opts     []Option
+		method   string
+		wantBody string
+		wantLog  bool
+		wantErr  error
+	}{
+		{name: "DELETE body is allowed by default", method: http.MethodDelete, wantBody: `{"id":1}`},
+		{name: "POST body is allowed by default", method: http.MethodPost, wantBody: `{"id":1}`},
+		{name: "GET body is skipped by default", method: http.MethodGet, wantBody: ""},
+		{
+			name:     "DELETE warning is opted in",
+			opts:     []Option{WithBodyPolicy(BodyWarn, http.MethodDelete)},
+			method:   http.MethodDelete,
+			wantBody: `{"id":1}`,
+			wantLog:  true,
+		},
+		{
+			name:    "DELETE body is rejected",
+			opts:    []Option{WithBodyPolicy(BodyReject, http.MethodDelete)},
+			method:  http.MethodDelete,
+			wantErr: ErrBodyNotAllowed,
+		},
+		{
+			name:     "GET body is allowed",
+			opts:     []Option{WithBodyPolicy(BodyAllow, http.MethodGet)},
+			method:   http.MethodGet,
+			wantBody: `{"id":1}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			api := NewAPI(append([]Option{
+				WithBaseURL("https://example.com"),
+				WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+			}, tt.opts...)...)
+			rb := NewRequestBuilder[payload, Empty](api)
+			body := &payload{ID: 1}
+			switch tt.method {
+			case http.MethodDelete:
+				rb = rb.Delete("/items/1", body)
+			case http.MethodPost:
+				rb = rb.Post("/items", body)
+			case http.MethodGet:
+				rb = rb.Get("/items")
+				rb.body = body // GET has no body argument
+			}
+			req, err := rb.Build(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			b, err := requestBody(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(bytes.TrimSpace(b)); got != tt.wantBody {
+				t.Errorf("got body %q, want %q", got, tt.wantBody)
+			}
+			if gotLog := strings.Contains(logs.String(), "request body is sent"); gotLog != tt.wantLog {
+				t.Errorf("got warning logged %v, want %v: %q", gotLog, tt.wantLog, logs.String())
+			}
+		})
+	}
+}
+
+func BenchmarkRetryBody(b *testing.B) {
+	const retries = 5
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	api := NewAPI(
+		WithBaseURL(srv.URL),
+		WithRetry(retries, 0, 0, ConstantBackoff, func(resp *http.Response, err error) bool {
+			return resp != nil && resp.StatusCode == http.StatusServiceUnavailable
+		}),
+	)
+	payload := bytes.Repeat([]byte("x"), 1<<20)
+	nonReplayableBody := func(req *http.Request) error {
+		req.Body = io.NopCloser(bytes.NewReader(payload))
+		req.GetBody = nil
+		req.ContentLength = int64(len(payload))
+		return nil
+	}
+
+	b.SetBytes(int64(len(payload)) * (retries + 1))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resp, err := NewRequestBuilder[Empty, Empty](api).Post("/upload", nil, nonReplayableBody).Do(context.Background())
+		if err != nil {
+			b.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+//
+// Package clientxtest provides test doubles of clientx.Limiter and clientx.Retrier,
+// so code built on top of clientx can be tested without real waiting.
+//
+//	limiter := clientxtest.NewLimiter()
+//	retrier := clientxtest.NewRetrier(time.Millisecond, time.Millisecond)
+//	api := clientx.NewAPI(
+//		clientx.WithBaseURL(srv.URL),
+//		clientx.WithLimiter(limiter),
+//		clientx.WithRetrier(retrier.New),
+//		clientx.WithRetry(0, 0, 0, nil, retryOn503),
+//	)
+package clientxtest
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/0x9ef/clientx"
+	"golang.org/x/time/rate"
+)
+
+// Limiter is a clientx.Limiter which never blocks and records calls.
+// Wait returns Err if it's set, or context error if context is done.
+type Limiter struct {
+	mu    sync.Mutex
+	waits int
+	limit rate.Limit
+	burst int
+	// Err is returned by Wait, e.g. clientx.ErrRateLimitExceeded to simulate exhausted limiter.
+	Err error
+}
+
+var (
+	_ clientx.Limiter          = (*Limiter)(nil)
+	_ clientx.AllowLimiter     = (*Limiter)(nil)
+	_ clientx.AvailableLimiter = (*Limiter)(nil)
+	_ clientx.LimitReporter    = (*Limiter)(nil)
+)
+
+// NewLimiter returns unlimited Limiter.
+func NewLimiter() *Limiter {
+	return &Limiter{
+		limit: rate.Inf,
+		burst: 1,
+	}
+}
+
+func (l *Limiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.waits++
+	if l.Err != nil {
+		return l.Err
+	}
+	return ctx.Err()
+}
+
+// WaitAvailable behaves as Wait, n is ignored.
+func (l *Limiter) WaitAvailable(ctx context.Context, _ int) error {
+	return l.Wait(ctx)
+}
+
+// Allow reports true unless Err is set, it's counted as a wait.
+func (l *Limiter) Allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.waits++
+	return l.Err == nil
+}
+
+// SetBurstAt sets burst immediately, at is ignored.
+func (l *Limiter) SetBurstAt(_ time.Time, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.burst = burst
+}
+
+// SetLimitAt sets limit immediately, at is ignored.
+func (l *Limiter) SetLimitAt(_ time.Time, limit rate.Limit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+}
+
+func (l *Limiter) Limit() rate.Limit {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+func (l *Limiter) Burst() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.burst
+}
+
+// Waits returns number of Wait, WaitAvailable and Allow calls.
+func (l *Limiter) Waits() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.waits
+}
+
+// Retrier is a clientx.Retrier which returns predefined delays one by one and then clientx.StopBackoff.
+// It counts all Next calls, including the ones returned StopBackoff.
+type Retrier struct {
+	mu      sync.Mutex
+	delays  []time.Duration
+	attempt int64
+	calls   *int64 // shared with retriers returned by New
+}
+
+var _ clientx.Retrier = (*Retrier)(nil)
+
+// NewRetrier returns Retrier which allows len(delays) retries.
+func NewRetrier(delays ...time.Duration) *Retrier {
+	return &Retrier{delays: delays, calls: new(int64)}
+}
+
+// New returns a fresh Retrier with the same delays, so it can be passed into clientx.WithRetrier
+// and each request is allowed len(delays) retries. Next calls of all returned retriers are counted by r.
+func (r *Retrier) New() clientx.Retrier {
+	return &Retrier{delays: r.delays, calls: r.calls}
+}
+
+func (r *Retrier) Next() time.Duration {
+	atomic.AddInt64(r.calls, 1)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.attempt >= int64(len(r.delays)) {
+		return clientx.StopBackoff
+	}
+	d := r.delays[r.attempt]
+	r.attempt++
+	return d
+}
+
+func (r *Retrier) Reset() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := r.attempt
+	r.attempt = 0
+	return n
+}
+
+func (r *Retrier) Attempt() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.attempt
+}
+
+// Calls returns number of Next calls of r and all retriers returned by New.
+func (r *Retrier) Calls() int {
+	return int(atomic.LoadInt64(r.calls))
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientxtest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/0x9ef/clientx"
+)
+
+func TestRetrierSequentialRequests(t *testing.T) {
+	// Server fails requests by script: the first request once, the second one twice
+	script := []int{
+		http.StatusServiceUnavailable, http.StatusOK,
+		http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK,
+	}
+	var calls int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&calls, 1) - 1
+		w.WriteHeader(script[n])
+	}))
+	defer srv.Close()
+
+	retrier := NewRetrier(time.Millisecond, time.Millisecond)
+	api := clientx.NewAPI(
+		clientx.WithBaseURL(srv.URL),
+		clientx.WithLimiter(NewLimiter()),
+		clientx.WithRetrier(retrier.New),
+		clientx.WithRetry(0, 0, 0, nil, func(resp *http.Response, err error) bool {
+			return resp != nil && resp.StatusCode == http.StatusServiceUnavailable
+		}),
+	)
+
+	for i, wantAttempts := range []int{2, 3} {
+		var stats clientx.RetryStats
+		resp, err := clientx.NewRequestBuilder[clientx.Empty, clientx.Empty](api).
+			Get("/").
+			CaptureRetryStats(&stats).
+			Do(context.Background())
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("request %d: got status %d, want %d", i, resp.StatusCode, http.StatusOK)
+		}
+		if stats.Attempts != wantAttempts {
+			t.Errorf("request %d: got %d attempts, want %d", i, stats.Attempts, wantAttempts)
+		}
+	}
+	if got := retrier.Calls(); got != 3 {
+		t.Errorf("got %d Next calls, want 3", got)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const redactedValue = "[REDACTED]"
+
+// ToCurl renders request as an equivalent curl command, includes method, URL, headers and body.
+// Values of redactHeaders (case-insensitive) are replaced with [REDACTED], e.g. Authorization.
+// The request body isn't consumed if GetBody is set (requests built by RequestBuilder always have it).
+func ToCurl(req *http.Request, redactHeaders ...string) (string, error) {
+	redact := make(map[string]bool, len(redactHeaders))
+	for _, h := range redactHeaders {
+		redact[http.CanonicalHeaderKey(h)] = true
+	}
+
+	var b strings.Builder
+	b.WriteString("curl -X ")
+	b.WriteString(req.Method)
+	b.WriteString(" ")
+	b.WriteString(shellQuote(req.URL.String()))
+
+	keys := make([]string, 0, len(req.Header))
+	for key := range req.Header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, val := range req.Header[key] {
+			if redact[http.CanonicalHeaderKey(key)] {
+				val = redactedValue
+			}
+			b.WriteString(" -H ")
+			b.WriteString(shellQuote(key + ": " + val))
+		}
+	}
+
+	body, err := requestBody(req)
+	if err != nil {
+		return "", err
+	}
+	if len(body) != 0 {
+		b.WriteString(" --data-raw ")
+		b.WriteString(shellQuote(string(body)))
+	}
+	return b.String(), nil
+}
+
+// Curl builds request (see Build) and renders it as curl command (see ToCurl).
+func (rb *RequestBuilder[Req, Resp]) Curl(ctx context.Context, redactHeaders ...string) (string, error) {
+	req, err := rb.Build(ctx)
+	if err != nil {
+		return "", err
+	}
+	return ToCurl(req, redactHeaders...)
+}
+
+// shellQuote wraps s in single quotes, so it's passed to shell as is.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// ErrUnknownDiscriminator is returned when value of discriminator field isn't registered.
+var ErrUnknownDiscriminator = errors.New("unknown discriminator value")
+
+// Discriminator is JSON EncoderDecoder for polymorphic responses, where value of discriminator field
+// (e.g. "type") determines concrete type of the object. Response is decoded into pointer to registered type,
+// so Resp is either any or an interface implemented by registered types:
+//
+//	d := clientx.NewDiscriminator("type").
+//		Register("cat", Cat{}).
+//		Register("dog", Dog{})
+//	pet, err := clientx.NewRequestBuilder[struct{}, any](api).
+//		Get("/pets/1").
+//		DoWithDecode(ctx, d) // *pet is *Cat or *Dog
+type Discriminator struct {
+	field string
+	types map[string]reflect.Type
+}
+
+var _ EncoderDecoder = (*Discriminator)(nil)
+
+// NewDiscriminator returns Discriminator which selects type by value of JSON field.
+func NewDiscriminator(field string) *Discriminator {
+	return &Discriminator{
+		field: field,
+		types: make(map[string]reflect.Type),
+	}
+}
+
+// Register registers type of sample (e.g. Cat{}) for discriminator value. Objects are decoded into
+// pointer to the type (*Cat). Pointer samples are dereferenced.
+func (d *Discriminator) Register(value string, sample any) *Discriminator {
+	typ := reflect.TypeOf(sample)
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	d.types[value] = typ
+	return d
+}
+
+// Unmarshal decodes JSON object into pointer to type registered for value of discriminator field.
+func (d *Discriminator) Unmarshal(b []byte) (any, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, fmt.Errorf("failed to decode polymorphic JSON object: %w", err)
+	}
+	raw, ok := fields[d.field]
+	if !ok {
+		return nil, fmt.Errorf("JSON object doesn't contain discriminator field %q", d.field)
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, fmt.Errorf("discriminator field %q isn't a string: %w", d.field, err)
+	}
+	typ, ok := d.types[value]
+	if !ok {
+		return nil, fmt.Errorf("%w %q of field %q", ErrUnknownDiscriminator, value, d.field)
+	}
+
+	v := reflect.New(typ)
+	if err := json.Unmarshal(b, v.Interface()); err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+// Decode decodes JSON object (see Unmarshal) into dst, which is a pointer to any or to an interface
+// implemented by registered types.
+func (d *Discriminator) Decode(r io.Reader, dst any) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	v, err := d.Unmarshal(b)
+	if err != nil {
+		return err
+	}
+
+	dv := reflect.ValueOf(dst)
+	if dv.Kind() != reflect.Pointer || dv.IsNil() {
+		return fmt.Errorf("invalid destination %T", dst)
+	}
+	val := reflect.ValueOf(v)
+	if !val.Type().AssignableTo(dv.Elem().Type()) {
+		return fmt.Errorf("decoded %T isn't assignable to %s", v, dv.Elem().Type())
+	}
+	dv.Elem().Set(val)
+	return nil
+}
+
+// Encode encodes v as JSON (see JSONEncoderDecoder).
+func (d *Discriminator) Encode(w io.Writer, v any) error {
+	return JSONEncoderDecoder.Encode(w, v)
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testPet interface {
+	Sound() string
+}
+
+type testCat struct {
+	Type  string `json:"type"`
+	Lives int    `json:"lives"`
+}
+
+func (testCat) Sound() string { return "meow" }
+
+type testDog struct {
+	Type  string `json:"type"`
+	Breed string `json:"breed"`
+}
+
+func (testDog) Sound() string { return "woof" }
+
+func newTestDiscriminator() *Discriminator {
+	return NewDiscriminator("type").
+		Register("cat", testCat{}).
+		Register("dog", &testDog{}) // pointer samples are dereferenced
+}
+
+func TestDiscriminatorUnmarshal(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		// want is nil if decoding must fail, wantErr is checked only if it's set.
+		want    any
+		wantErr error
+	}{
+		{name: "cat", body: `{"type":"cat","lives":9}`, want: &testCat{Type: "cat", Lives: 9}},
+		{name: "dog", body: `{"breed":"corgi","type":"dog"}`, want: &testDog{Type: "dog", Breed: "corgi"}},
+		{name: "unknown value", body: `{"type":"parrot"}`, wantErr: ErrUnknownDiscriminator},
+		{name: "value of different case", body: `{"type":"Cat"}`, wantErr: ErrUnknownDiscriminator},
+		{name: "missing field", body: `{"lives":9}`},
+		{name: "null field", body: `{"type":null}`, wantErr: ErrUnknownDiscriminator},
+		{name: "field isn't a string", body: `{"type":1}`},
+		{name: "not an object", body: `["cat"]`},
+		{name: "invalid JSON", body: `{"type":`},
+		{name: "invalid fields of registered type", body: `{"type":"cat","lives":"nine"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newTestDiscriminator().Unmarshal([]byte(tt.body))
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("got %#v, want error", got)
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Errorf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscriminatorDecode(t *testing.T) {
+	d := newTestDiscriminator()
+
+	var pet testPet
+	if err := d.Decode(strings.NewReader(`{"type":"dog","breed":"corgi"}`), &pet); err != nil {
+		t.Fatal(err)
+	}
+	if pet.Sound() != "woof" {
+		t.Errorf("got %T, want *testDog", pet)
+	}
+
+	var v any
+	if err := d.Decode(strings.NewReader(`{"type":"cat"}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := v.(*testCat); !ok {
+		t.Errorf("got %T, want *testCat", v)
+	}
+
+	var notPet io.Reader
+	if err := d.Decode(strings.NewReader(`{"type":"cat"}`), &notPet); err == nil {
+		t.Error("got no error, want error for destination which registered type isn't assignable to")
+	}
+	if err := d.Decode(strings.NewReader(`{"type":"cat"}`), v); err == nil {
+		t.Error("got no error, want error for non-pointer destination")
+	}
+}
+
+func TestDiscriminatorDoWithDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr error
+	}{
+		{"known value", `{"type":"cat","lives":9}`, "meow", nil},
+		{"unknown value", `{"type":"parrot"}`, "", ErrUnknownDiscriminator},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+
+			api := NewAPI(WithBaseURL(srv.URL))
+			pet, err := NewRequestBuilder[Empty, testPet](api).Get("/pets/1").DoWithDecode(context.Background(), newTestDiscriminator())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (*pet).Sound() != tt.want {
+				t.Errorf("got %T, want pet which says %q", *pet, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// ErrRangeMismatch is returned when server responds with a range that doesn't continue the download.
+var ErrRangeMismatch = errors.New("content range doesn't match requested offset")
+
+// Download executes request and streams response body into w. If the transfer is interrupted,
+// the request is repeated with Range header (bytes=N-) starting from the last received byte,
+// at most maxResumes times. If server ignores Range and responds with 200 (full body), the download
+// is restarted: w is truncated if it's a file (implements Truncate and Seek, e.g. *os.File), otherwise
+// already received bytes are skipped. Returns number of bytes written to w (size of the download).
+//
+// Each resume is sent through the same pipeline as Do (rate limiter, retries, hooks, observers, logging),
+// OnFinish hooks are executed once for the whole download. Unlike Do, the response body isn't buffered
+// into memory unless there are AfterResponse hooks.
+func (rb *RequestBuilder[Req, Resp]) Download(ctx context.Context, w io.Writer, maxResumes int) (written int64, err error) {
+	c := rb.client
+	ctx, cancel := c.api.operationContext(ctx)
+	defer cancel()
+
+	var (
+		httpResp *http.Response
+		stats    RetryStats
+	)
+	defer func() {
+		rb.finish(httpResp, err, stats)
+	}()
+
+	release, err := c.api.acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	httpReq, err := c.buildRequest(ctx, rb, c.api.defaultEncoderDecoder())
+	if err != nil {
+		return 0, err
+	}
+	rb.lastRequest.Store(httpReq)
+
+	for attempt := 0; ; attempt++ {
+		var nextStats RetryStats
+		httpResp, written, nextStats, err = c.downloadFrom(ctx, rb, httpReq, w, written)
+		stats.add(nextStats)
+		if err == nil {
+			return written, nil
+		}
+		if !isResumable(err) || attempt >= maxResumes || ctx.Err() != nil {
+			return written, err
+		}
+	}
+}
+
+// errInterrupted wraps errors that occurred while reading response body.
+type errInterrupted struct{ err error }
+
+func (e *errInterrupted) Error() string { return "download interrupted: " + e.err.Error() }
+func (e *errInterrupted) Unwrap() error { return e.err }
+
+func isResumable(err error) bool {
+	var interrupted *errInterrupted
+	return errors.As(err, &interrupted)
+}
+
+// downloadFrom requests content starting from offset and copies it into w.
+// Returns number of bytes downloaded so far, including the ones written before offset.
+func (c *client[Req, Resp]) downloadFrom(ctx context.Context, rb *RequestBuilder[Req, Resp], httpReq *http.Request, w io.Writer, offset int64) (*http.Response, int64, RetryStats, error) {
+	req := httpReq.Clone(ctx)
+	if offset > 0 {
+		if err := WithRange(offset, -1)(req); err != nil {
+			return nil, 0, RetryStats{}, err
+		}
+	}
+
+	resp, reader, stats, err := c.sendRequest(ctx, rb, req, 0, true)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			// Connection-level errors may be transient, so give a chance to resume
+			return nil, offset, stats, &errInterrupted{err}
+		}
+		return nil, offset, stats, err
+	}
+	defer reader.Close()
+	n, err := copyRange(w, resp, reader, offset)
+	return resp, n, stats, err
+}
+
+// truncater is implemented by writers which can be rewritten from the beginning, e.g. *os.File.
+type truncater interface {
+	io.Seeker
+	Truncate(size int64) error
+}
+
+// copyRange copies body of response to ranged request into w starting from offset.
+// Returns number of bytes downloaded so far, including the ones written before offset.
+func copyRange(w io.Writer, resp *http.Response, body io.Reader, offset int64) (int64, error) {
+	var skip int64
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// Server ignored Range header and sent full body, restart the download
+		if t, ok := w.(truncater); ok && offset > 0 {
+			if err := t.Truncate(0); err != nil {
+				return offset, err
+			}
+			if _, err := t.Seek(0, io.SeekStart); err != nil {
+				return offset, err
+			}
+			offset = 0
+		}
+		skip = offset // w can't be rewritten, skip what we already have
+	case http.StatusPartialContent:
+		cr, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || cr.Start < 0 {
+			return offset, fmt.Errorf("invalid Content-Range: %q", resp.Header.Get("Content-Range"))
+		}
+		if cr.Start > offset {
+			return offset, fmt.Errorf("%w: got %d, want %d", ErrRangeMismatch, cr.Start, offset)
+		}
+		skip = offset - cr.Start
+	case http.StatusRequestedRangeNotSatisfiable:
+		if offset > 0 {
+			// Nothing left to download
+			return offset, nil
+		}
+		return offset, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	default:
+		return offset, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	if skip > 0 {
+		if _, err := io.CopyN(io.Discard, body, skip); err != nil {
+			return offset, &errInterrupted{err}
+		}
+	}
+
+	n, err := io.Copy(w, interruptReader{body})
+	return offset + n, err
+}
+
+// interruptReader marks body read errors as resumable, so they can be distinguished from write errors.
+type interruptReader struct{ r io.Reader }
+
+func (r interruptReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = &errInterrupted{err}
+	}
+	return n, err
+}
@@ -0,0 +1,256 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const downloadContent = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// interruptResponse sends body and breaks the connection. If size is positive, it's sent as Content-Length,
+// otherwise body is chunked; in both cases client sees unexpected EOF.
+func interruptResponse(t *testing.T, w http.ResponseWriter, status int, size int, body string) {
+	if size > 0 {
+		w.Header().Set("Content-Length", strconv.Itoa(size))
+	}
+	w.WriteHeader(status)
+	io.WriteString(w, body)
+	w.(http.Flusher).Flush()
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	conn.Close()
+}
+
+// downloadServer serves attempts of download one by one, ranges are recorded.
+type downloadServer struct {
+	mu       sync.Mutex
+	attempts []func(w http.ResponseWriter, r *http.Request)
+	ranges   []string
+}
+
+func (s *downloadServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	i := len(s.ranges)
+	s.ranges = append(s.ranges, r.Header.Get("Range"))
+	s.mu.Unlock()
+	if i >= len(s.attempts) {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	s.attempts[i](w, r)
+}
+
+func TestDownload(t *testing.T) {
+	half := len(downloadContent) / 2
+	interrupted := func(t *testing.T) func(w http.ResponseWriter, r *http.Request) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			interruptResponse(t, w, http.StatusOK, len(downloadContent), downloadContent[:half])
+		}
+	}
+	partial := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", half, len(downloadContent)-1, len(downloadContent)))
+		w.WriteHeader(http.StatusPartialContent)
+		io.WriteString(w, downloadContent[half:])
+	}
+	full := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, downloadContent)
+	}
+	tests := []struct {
+		name       string
+		attempts   func(t *testing.T) []func(w http.ResponseWriter, r *http.Request)
+		maxResumes int
+		wantRanges []string
+		wantErr    error
+	}{
+		{
+			name: "without interruption",
+			attempts: func(t *testing.T) []func(w http.ResponseWriter, r *http.Request) {
+				return []func(w http.ResponseWriter, r *http.Request){full}
+			},
+			maxResumes: 1,
+			wantRanges: []string{""},
+		},
+		{
+			name: "resumed with Range",
+			attempts: func(t *testing.T) []func(w http.ResponseWriter, r *http.Request) {
+				return []func(w http.ResponseWriter, r *http.Request){interrupted(t), partial}
+			},
+			maxResumes: 1,
+			wantRanges: []string{"", fmt.Sprintf("bytes=%d-", half)},
+		},
+		{
+			name: "resumed twice",
+			attempts: func(t *testing.T) []func(w http.ResponseWriter, r *http.Request) {
+				return []func(w http.ResponseWriter, r *http.Request){
+					interrupted(t),
+					func(w http.ResponseWriter, r *http.Request) {
+						w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", half, len(downloadContent)-1, len(downloadContent)))
+						interruptResponse(t, w, http.StatusPartialContent, len(downloadContent)-half, downloadContent[half:half+5])
+					},
+					func(w http.ResponseWriter, r *http.Request) {
+						w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", half+5, len(downloadContent)-1, len(downloadContent)))
+						w.WriteHeader(http.StatusPartialContent)
+						io.WriteString(w, downloadContent[half+5:])
+					},
+				}
+			},
+			maxResumes: 2,
+			wantRanges: []string{"", fmt.Sprintf("bytes=%d-", half), fmt.Sprintf("bytes=%d-", half+5)},
+		},
+		{
+			name: "Range is ignored",
+			attempts: func(t *testing.T) []func(w http.ResponseWriter, r *http.Request) {
+				return []func(w http.ResponseWriter, r *http.Request){interrupted(t), full}
+			},
+			maxResumes: 1,
+			wantRanges: []string{"", fmt.Sprintf("bytes=%d-", half)},
+		},
+		{
+			name: "already complete",
+			attempts: func(t *testing.T) []func(w http.ResponseWriter, r *http.Request) {
+				return []func(w http.ResponseWriter, r *http.Request){
+					func(w http.ResponseWriter, r *http.Request) {
+						// Whole body is received, but the connection is broken before the end of chunked body
+						interruptResponse(t, w, http.StatusOK, 0, downloadContent)
+					},
+					func(w http.ResponseWriter, r *http.Request) {
+						w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", len(downloadContent)))
+						w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+					},
+				}
+			},
+			maxResumes: 1,
+			wantRanges: []string{"", fmt.Sprintf("bytes=%d-", len(downloadContent))},
+		},
+		{
+			name: "range mismatch",
+			attempts: func(t *testing.T) []func(w http.ResponseWriter, r *http.Request) {
+				return []func(w http.ResponseWriter, r *http.Request){
+					interrupted(t),
+					func(w http.ResponseWriter, r *http.Request) {
+						w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", half+1, len(downloadContent)-1, len(downloadContent)))
+						w.WriteHeader(http.StatusPartialContent)
+						io.WriteString(w, downloadContent[half+1:])
+					},
+				}
+			},
+			maxResumes: 1,
+			wantRanges: []string{"", fmt.Sprintf("bytes=%d-", half)},
+			wantErr:    ErrRangeMismatch,
+		},
+		{
+			name: "resumes are exhausted",
+			attempts: func(t *testing.T) []func(w http.ResponseWriter, r *http.Request) {
+				return []func(w http.ResponseWriter, r *http.Request){interrupted(t), partial}
+			},
+			maxResumes: 0,
+			wantRanges: []string{""},
+			wantErr:    io.ErrUnexpectedEOF,
+		},
+	}
+	writers := []struct {
+		name string
+		new  func(t *testing.T) (io.Writer, func() string)
+	}{
+		{"buffer", func(t *testing.T) (io.Writer, func() string) {
+			var buf bytes.Buffer
+			return &buf, buf.String
+		}},
+		{"file", func(t *testing.T) (io.Writer, func() string) {
+			f, err := os.Create(filepath.Join(t.TempDir(), "download"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { f.Close() })
+			return f, func() string {
+				b, err := os.ReadFile(f.Name())
+				if err != nil {
+					t.Fatal(err)
+				}
+				return string(b)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		for _, wr := range writers {
+			t.Run(tt.name+"/"+wr.name, func(t *testing.T) {
+				ds := &downloadServer{attempts: tt.attempts(t)}
+				srv := httptest.NewServer(ds)
+				defer srv.Close()
+
+				w, content := wr.new(t)
+				api := NewAPI(WithBaseURL(srv.URL))
+				written, err := NewRequestBuilder[Empty, Empty](api).Get("/file").Download(context.Background(), w, tt.maxResumes)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				if strings.Join(ds.ranges, ",") != strings.Join(tt.wantRanges, ",") {
+					t.Errorf("got ranges %q, want %q", ds.ranges, tt.wantRanges)
+				}
+				if err != nil {
+					return
+				}
+				if got := content(); got != downloadContent {
+					t.Errorf("got content %q, want %q", got, downloadContent)
+				}
+				if written != int64(len(downloadContent)) {
+					t.Errorf("got %d bytes written, want %d", written, len(downloadContent))
+				}
+			})
+		}
+	}
+}
+
+func TestDownloadRestartTruncatesFile(t *testing.T) {
+	updated := strings.Repeat("x", len(downloadContent))
+	ds := &downloadServer{attempts: []func(w http.ResponseWriter, r *http.Request){
+		func(w http.ResponseWriter, r *http.Request) {
+			interruptResponse(t, w, http.StatusOK, len(downloadContent), downloadContent[:10])
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			// Range is ignored, full body is sent again
+			io.WriteString(w, updated)
+		},
+	}}
+	srv := httptest.NewServer(ds)
+	defer srv.Close()
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "download"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	api := NewAPI(WithBaseURL(srv.URL))
+	written, err := NewRequestBuilder[Empty, Empty](api).Get("/file").Download(context.Background(), f, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Bytes received before interruption are rewritten with the new full body
+	if string(b) != updated {
+		t.Errorf("got content %q, want %q", b, updated)
+	}
+	if written != int64(len(updated)) {
+		t.Errorf("got %d bytes written, want %d", written, len(updated))
+	}
+}
@@ -1,8 +1,11 @@
 package clientx
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/json"
 	"encoding/xml"
+	"fmt"
 	"io"
 )
 
@@ -11,6 +14,12 @@ type EncoderDecoder interface {
 	Decoder
 }
 
+// ContentTyper is an optional interface of EncoderDecoder. If implemented, its content type is used
+// to set Content-Type header of encoded request body and Accept header (unless they're set explicitly).
+type ContentTyper interface {
+	ContentType() string
+}
+
 // Encoder is a general interface responsibles for encoding payloads.
 type Encoder interface {
 	Encode(w io.Writer, v any) error
@@ -24,14 +33,89 @@ type Decoder interface {
 // JSON Encoder/Decoder realization.
 var JSONEncoderDecoder = &jsonEncoderDecoder{}
 
-type jsonEncoderDecoder struct{}
+type (
+	JSONOption         func(*jsonEncoderDecoder)
+	jsonEncoderDecoder struct {
+		useNumber           bool
+		omitTrailingNewline bool
+	}
+)
+
+// NewJSONEncoderDecoder returns JSON Encoder/Decoder with applied options.
+// Without options it behaves the same as JSONEncoderDecoder.
+func NewJSONEncoderDecoder(opts ...JSONOption) EncoderDecoder {
+	enc := &jsonEncoderDecoder{}
+	for _, opt := range opts {
+		opt(enc)
+	}
+	return enc
+}
+
+// JSONUseNumber makes decoder to unmarshal numbers into interface{} (any) as json.Number instead of float64.
+// It keeps precision of big integers (e.g. 64-bit snowflake IDs) that can't be represented by float64,
+// but the caller has to convert json.Number into int64/float64 explicitly via Int64/Float64 methods.
+// Doesn't affect numbers decoded into typed struct fields.
+func JSONUseNumber() JSONOption {
+	return func(enc *jsonEncoderDecoder) {
+		enc.useNumber = true
+	}
+}
+
+// JSONOmitTrailingNewline makes encoder to omit '\n' which json.Encoder appends to every encoded value,
+// so encoded body is byte-to-byte equal to json.Marshal output. Use it for strict servers or signature
+// schemes that hash exact body bytes. By default the newline is kept for backward compatibility.
+func JSONOmitTrailingNewline() JSONOption {
+	return func(enc *jsonEncoderDecoder) {
+		enc.omitTrailingNewline = true
+	}
+}
 
-func (jsonEncoderDecoder) Encode(w io.Writer, v any) error {
+func (enc jsonEncoderDecoder) Encode(w io.Writer, v any) error {
+	if enc.omitTrailingNewline {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	}
 	return json.NewEncoder(w).Encode(v)
 }
 
-func (jsonEncoderDecoder) Decode(r io.Reader, dst any) error {
-	return json.NewDecoder(r).Decode(dst)
+func (enc jsonEncoderDecoder) Decode(r io.Reader, dst any) error {
+	dec := json.NewDecoder(r)
+	if enc.useNumber {
+		dec.UseNumber()
+	}
+	return dec.Decode(dst)
+}
+
+// jsonDataFieldDecoder decodes named field of JSON envelope with the underlying decoder.
+type jsonDataFieldDecoder struct {
+	EncoderDecoder
+	field    string
+	envelope any
+}
+
+func (dec *jsonDataFieldDecoder) Decode(r io.Reader, dst any) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return fmt.Errorf("failed to decode JSON envelope: %w", err)
+	}
+	data, ok := fields[dec.field]
+	if !ok {
+		return fmt.Errorf("JSON envelope doesn't contain %q field", dec.field)
+	}
+	if dec.envelope != nil {
+		if err := json.Unmarshal(b, dec.envelope); err != nil {
+			return fmt.Errorf("failed to decode JSON envelope: %w", err)
+		}
+	}
+	return dec.EncoderDecoder.Decode(bytes.NewReader(data), dst)
 }
 
 // XML Encoder/Decoder realization.
@@ -47,6 +131,67 @@ func (xmlEncoderDecoder) Decode(r io.Reader, dst any) error {
 	return xml.NewDecoder(r).Decode(dst)
 }
 
+// LengthPrefixedMaxFrameSize is a maximum frame size accepted by length-prefixed decoder, it protects
+// from allocating huge buffers because of corrupted prefix.
+const LengthPrefixedMaxFrameSize = 64 << 20
+
+// NewLengthPrefixedEncoderDecoder returns Encoder/Decoder for binary protocols which frame messages
+// with 4-byte big-endian length prefix. Payload of the frame is marshaled/unmarshaled by user-supplied functions.
+// Response body is never treated as text, so binary payloads are passed to unmarshal as is.
+//
+//	enc := clientx.NewLengthPrefixedEncoderDecoder(
+//		func(v any) ([]byte, error) { return v.(*Frame).MarshalBinary() },
+//		func(b []byte, dst any) error { return dst.(*Frame).UnmarshalBinary(b) },
+//	)
+func NewLengthPrefixedEncoderDecoder(marshal func(v any) ([]byte, error), unmarshal func(b []byte, dst any) error) EncoderDecoder {
+	return &lengthPrefixedEncoderDecoder{
+		marshal:   marshal,
+		unmarshal: unmarshal,
+	}
+}
+
+type lengthPrefixedEncoderDecoder struct {
+	marshal   func(v any) ([]byte, error)
+	unmarshal func(b []byte, dst any) error
+}
+
+func (lengthPrefixedEncoderDecoder) ContentType() string {
+	return "application/octet-stream"
+}
+
+func (enc lengthPrefixedEncoderDecoder) Encode(w io.Writer, v any) error {
+	b, err := enc.marshal(v)
+	if err != nil {
+		return err
+	}
+	if uint64(len(b)) > LengthPrefixedMaxFrameSize {
+		return fmt.Errorf("frame size %d exceeds limit %d", len(b), LengthPrefixedMaxFrameSize)
+	}
+	var prefix [4]byte
+	binary.BigEndian.PutUint32(prefix[:], uint32(len(b)))
+	if _, err := w.Write(prefix[:]); err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func (enc lengthPrefixedEncoderDecoder) Decode(r io.Reader, dst any) error {
+	var prefix [4]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return fmt.Errorf("failed to read frame length: %w", err)
+	}
+	n := binary.BigEndian.Uint32(prefix[:])
+	if n > LengthPrefixedMaxFrameSize {
+		return fmt.Errorf("frame size %d exceeds limit %d", n, LengthPrefixedMaxFrameSize)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return fmt.Errorf("failed to read frame: %w", err)
+	}
+	return enc.unmarshal(b, dst)
+}
+
 // Blank (No Action) Encoder/Decoder realization.
 var BlankEncoderDecoder = &blankEncoderDecoder{}
 
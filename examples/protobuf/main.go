@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/0x9ef/clientx"
+	"github.com/0x9ef/clientx/protobuf"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type EchoAPI struct {
+	*clientx.API
+}
+
+func New(api *clientx.API) *EchoAPI {
+	return &EchoAPI{
+		API: api,
+	}
+}
+
+// Echo sends protobuf message and decodes protobuf response. Any generated message can be used
+// instead of wrapperspb.StringValue, as far as *Req and *Resp implement proto.Message.
+func (api *EchoAPI) Echo(ctx context.Context, msg string, opts ...clientx.RequestOption) (*wrapperspb.StringValue, error) {
+	return clientx.NewRequestBuilder[wrapperspb.StringValue, wrapperspb.StringValue](api.API).
+		Post("/echo", wrapperspb.String(msg), opts...).
+		DoWithDecode(ctx, protobuf.EncoderDecoder)
+}
+
+func main() {
+	api := New(
+		clientx.NewAPI(
+			clientx.WithBaseURL("http://localhost:8080"),
+			clientx.WithRateLimit(10, 2, time.Second),
+		),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	resp, err := api.Echo(ctx, "hello")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("Echo:", resp.GetValue())
+}
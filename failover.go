@@ -0,0 +1,105 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"slices"
+)
+
+// WithFailover sets secondary base URLs. When request to the base URL fails with connection error
+// or 5xx status code (after all retries), it's repeated against the next URL in order.
+// Each URL gets its own retry sequence. The base URL of the last successful response
+// is reported by API.LastSuccessfulBaseURL.
+func WithFailover(urls ...string) Option {
+	return func(o *Options) {
+		o.Failover = append(o.Failover, urls...)
+	}
+}
+
+// LastSuccessfulBaseURL returns base URL which served the last successful response (non-5xx).
+// Returns empty string if there were no successful responses yet.
+func (api *API) LastSuccessfulBaseURL() string {
+	u, _ := api.lastBaseURL.Load().(string)
+	return u
+}
+
+// failoverQuery replaces query params of primary base URL in query of request with ones of failover base URL,
+// query params set by request options are kept.
+func failoverQuery(query, primary, failover url.Values) url.Values {
+	for key, vals := range primary {
+		if slices.Equal(query[key], vals) {
+			delete(query, key)
+		}
+	}
+	for key, vals := range failover {
+		if _, ok := query[key]; !ok {
+			query[key] = vals
+		}
+	}
+	return query
+}
+
+func isFailoverNeeded(resp *http.Response, err error) bool {
+	return err != nil || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// executeWithFailover executes request against base URL of httpReq, then against failover URLs
+// until response is successful. Returns accumulated retry stats of all base URLs.
+func (c *client[Req, Resp]) executeWithFailover(ctx context.Context, httpReq *http.Request, req *RequestBuilder[Req, Resp]) (*http.Response, RetryStats, error) {
+	baseURL, _ := c.api.baseURL(req.endpoint) // already validated by buildRequest
+	resp, stats, err := c.executeRequest(ctx, httpReq, req)
+	for _, next := range c.api.options.Failover {
+		if !isFailoverNeeded(resp, err) || ctx.Err() != nil {
+			break
+		}
+		if httpReq.Body != nil && httpReq.Body != http.NoBody && httpReq.GetBody == nil {
+			break // body can't be sent again
+		}
+
+		nextReq, buildErr := c.failoverRequest(next, httpReq, req)
+		if buildErr != nil {
+			c.api.logger().Warn("failed to build failover request", "base_url", next, "error", buildErr)
+			continue
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		c.api.logger().Warn("failing over to secondary base URL", "from", baseURL, "to", next, "error", err)
+
+		var nextStats RetryStats
+		resp, nextStats, err = c.executeRequest(ctx, nextReq, req)
+		stats.add(nextStats)
+		baseURL = next
+	}
+	if !isFailoverNeeded(resp, err) {
+		c.api.lastBaseURL.Store(baseURL)
+	}
+	return resp, stats, err
+}
+
+// failoverRequest clones httpReq with URL built from baseURL.
+func (c *client[Req, Resp]) failoverRequest(baseURL string, httpReq *http.Request, req *RequestBuilder[Req, Resp]) (*http.Request, error) {
+	u, err := c.buildRequestURL(baseURL, req.resourcePath)
+	if err != nil {
+		return nil, err
+	}
+	if primaryURL, err := c.api.baseURL(req.endpoint); err == nil {
+		if primary, err := url.Parse(primaryURL); err == nil {
+			u.RawQuery = failoverQuery(httpReq.URL.Query(), primary.Query(), u.Query()).Encode()
+		}
+	}
+
+	nextReq := httpReq.Clone(httpReq.Context())
+	nextReq.URL = u
+	nextReq.Host = ""
+	if httpReq.GetBody != nil {
+		if nextReq.Body, err = httpReq.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	return nextReq, nil
+}
@@ -1,8 +1,18 @@
 module github.com/0x9ef/clientx
 
-go 1.19
+go 1.21
 
 require (
 	github.com/gorilla/schema v1.2.1
+	go.opentelemetry.io/otel v1.24.0
+	go.opentelemetry.io/otel/metric v1.24.0
+	golang.org/x/text v0.14.0
 	golang.org/x/time v0.5.0
+	google.golang.org/protobuf v1.34.1
+)
+
+require (
+	github.com/go-logr/logr v1.4.1 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	go.opentelemetry.io/otel/trace v1.24.0 // indirect
 )
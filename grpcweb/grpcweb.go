@@ -0,0 +1,249 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+//
+// Package grpcweb provides unary gRPC-Web calls (binary protobuf format) on top of clientx.RequestBuilder.
+// Request message is framed with gRPC length-prefixed format, response data and trailer frames are parsed
+// and grpc-status is mapped to *Error.
+package grpcweb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/0x9ef/clientx"
+	"google.golang.org/protobuf/proto"
+)
+
+// ContentType is a media type of gRPC-Web requests and responses in binary protobuf format.
+const ContentType = "application/grpc-web+proto"
+
+// DefaultMaxFrameSize is a maximum size of response frame accepted by Invoke, unless it's changed by
+// WithMaxFrameSize. It protects from allocating huge buffers because of malformed length prefix.
+const DefaultMaxFrameSize = 4 << 20
+
+const (
+	frameHeaderSize = 5
+	flagCompressed  = 0x01
+	flagTrailer     = 0x80
+)
+
+type maxFrameSizeKey struct{}
+
+// WithMaxFrameSize sets maximum size of response frame (DefaultMaxFrameSize by default),
+// larger frames fail the call before their payload is read.
+func WithMaxFrameSize(n uint32) clientx.RequestOption {
+	return func(req *http.Request) error {
+		*req = *req.WithContext(context.WithValue(req.Context(), maxFrameSizeKey{}, n))
+		return nil
+	}
+}
+
+// maxFrameSize returns maximum frame size set by WithMaxFrameSize for req.
+func maxFrameSize(req *http.Request) uint32 {
+	if req != nil {
+		if n, ok := req.Context().Value(maxFrameSizeKey{}).(uint32); ok {
+			return n
+		}
+	}
+	return DefaultMaxFrameSize
+}
+
+// Code is a gRPC status code.
+type Code uint32
+
+const (
+	OK Code = iota
+	Canceled
+	Unknown
+	InvalidArgument
+	DeadlineExceeded
+	NotFound
+	AlreadyExists
+	PermissionDenied
+	ResourceExhausted
+	FailedPrecondition
+	Aborted
+	OutOfRange
+	Unimplemented
+	Internal
+	Unavailable
+	DataLoss
+	Unauthenticated
+)
+
+var codeNames = [...]string{
+	"OK", "Canceled", "Unknown", "InvalidArgument", "DeadlineExceeded", "NotFound", "AlreadyExists",
+	"PermissionDenied", "ResourceExhausted", "FailedPrecondition", "Aborted", "OutOfRange",
+	"Unimplemented", "Internal", "Unavailable", "DataLoss", "Unauthenticated",
+}
+
+func (c Code) String() string {
+	if int(c) < len(codeNames) {
+		return codeNames[c]
+	}
+	return "Code(" + strconv.FormatUint(uint64(c), 10) + ")"
+}
+
+// Error is returned when call completes with non-OK grpc-status.
+type Error struct {
+	Code    Code
+	Message string
+	// Trailers contains all trailers of the response, e.g. grpc-status-details-bin.
+	Trailers http.Header
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("grpc-web: %s: %s", e.Code, e.Message)
+}
+
+// CodeOf returns code of *Error in err chain, OK if err is nil and Unknown for other errors.
+func CodeOf(err error) Code {
+	if err == nil {
+		return OK
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return Unknown
+}
+
+// Invoke performs unary gRPC-Web call of method (full method name, e.g. "/echo.EchoService/Echo")
+// and decodes response message into Resp. Both *Req and *Resp must implement proto.Message.
+// Rate limiting, retries and hooks of the API are applied as for any other request.
+func Invoke[Req any, Resp any](ctx context.Context, api *clientx.API, method string, req *Req, opts ...clientx.RequestOption) (*Resp, error) {
+	in, ok := any(req).(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("grpc-web: %T doesn't implement proto.Message", req)
+	}
+	var resp Resp
+	out, ok := any(&resp).(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("grpc-web: %T doesn't implement proto.Message", &resp)
+	}
+
+	b, err := proto.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	frame := make([]byte, frameHeaderSize+len(b))
+	binary.BigEndian.PutUint32(frame[1:frameHeaderSize], uint32(len(b)))
+	copy(frame[frameHeaderSize:], b)
+
+	opts = append([]clientx.RequestOption{withHeaders}, opts...)
+	httpResp, err := clientx.NewRequestBuilder[Req, Resp](api).
+		PostRaw(method, func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(frame)), nil
+		}, opts...).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		if httpResp.Header.Get("Grpc-Status") != "" {
+			if err := statusError(httpResp.Header); err != nil {
+				return nil, err
+			}
+		}
+		return nil, fmt.Errorf("grpc-web: unexpected HTTP status %d", httpResp.StatusCode)
+	}
+
+	msg, trailers, err := readFrames(httpResp.Body, maxFrameSize(httpResp.Request))
+	if err != nil {
+		return nil, err
+	}
+	if trailers == nil {
+		// Trailers-only response, status is sent in headers
+		trailers = httpResp.Header
+	}
+	if err := statusError(trailers); err != nil {
+		return nil, err
+	}
+	if msg == nil {
+		return nil, errors.New("grpc-web: response doesn't contain message")
+	}
+	if err := proto.Unmarshal(msg, out); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func withHeaders(req *http.Request) error {
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set("Accept", ContentType)
+	req.Header.Set("X-Grpc-Web", "1")
+	return nil
+}
+
+// readFrames reads the first data frame and the trailer frame of response. Frames larger than maxSize are rejected.
+func readFrames(r io.Reader, maxSize uint32) (msg []byte, trailers http.Header, err error) {
+	var header [frameHeaderSize]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err == io.EOF {
+				return msg, trailers, nil
+			}
+			return nil, nil, fmt.Errorf("grpc-web: failed to read frame: %w", err)
+		}
+		flags := header[0]
+		n := binary.BigEndian.Uint32(header[1:])
+		if n > maxSize {
+			return nil, nil, fmt.Errorf("grpc-web: frame size %d exceeds limit %d", n, maxSize)
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return nil, nil, fmt.Errorf("grpc-web: failed to read frame: %w", err)
+		}
+		switch {
+		case flags&flagTrailer != 0:
+			if trailers, err = parseTrailers(payload); err != nil {
+				return nil, nil, err
+			}
+		case flags&flagCompressed != 0:
+			return nil, nil, errors.New("grpc-web: compressed messages aren't supported")
+		case msg == nil:
+			msg = payload
+		default:
+			return nil, nil, errors.New("grpc-web: unary response contains more than one message")
+		}
+	}
+}
+
+// parseTrailers parses trailer frame payload, which is HTTP/1 header block without terminating empty line.
+func parseTrailers(b []byte) (http.Header, error) {
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(append(b, "\r\n"...))))
+	h, err := r.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("grpc-web: failed to parse trailers: %w", err)
+	}
+	return http.Header(h), nil
+}
+
+func statusError(h http.Header) error {
+	status := h.Get("Grpc-Status")
+	if status == "" {
+		return errors.New("grpc-web: response doesn't contain grpc-status")
+	}
+	code, err := strconv.ParseUint(strings.TrimSpace(status), 10, 32)
+	if err != nil {
+		return fmt.Errorf("grpc-web: invalid grpc-status %q", status)
+	}
+	if Code(code) == OK {
+		return nil
+	}
+	msg, _ := url.PathUnescape(h.Get("Grpc-Message")) // percent-encoded
+	return &Error{Code: Code(code), Message: msg, Trailers: h}
+}
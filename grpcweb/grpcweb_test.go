@@ -0,0 +1,265 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package grpcweb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/0x9ef/clientx"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func frame(flags byte, payload string) string {
+	b := make([]byte, frameHeaderSize, frameHeaderSize+len(payload))
+	b[0] = flags
+	binary.BigEndian.PutUint32(b[1:], uint32(len(payload)))
+	return string(append(b, payload...))
+}
+
+func trailerFrame(trailers string) string {
+	return frame(flagTrailer, trailers)
+}
+
+func TestReadFrames(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		maxSize      uint32
+		wantMsg      string
+		wantTrailers http.Header
+		wantErr      string
+	}{
+		{
+			name:         "data and trailer frames",
+			body:         frame(0, "msg") + trailerFrame("grpc-status: 0\r\ngrpc-message: ok\r\n"),
+			wantMsg:      "msg",
+			wantTrailers: http.Header{"Grpc-Status": {"0"}, "Grpc-Message": {"ok"}},
+		},
+		{
+			name:         "trailer frame only",
+			body:         trailerFrame("grpc-status: 5\r\n"),
+			wantTrailers: http.Header{"Grpc-Status": {"5"}},
+		},
+		{
+			name:    "data frame without trailers",
+			body:    frame(0, "msg"),
+			wantMsg: "msg",
+		},
+		{
+			name:         "empty data frame",
+			body:         frame(0, "") + trailerFrame("grpc-status: 0\r\n"),
+			wantMsg:      "",
+			wantTrailers: http.Header{"Grpc-Status": {"0"}},
+		},
+		{name: "empty body"},
+		{name: "truncated header", body: frame(0, "msg")[:3], wantErr: "failed to read frame"},
+		{name: "truncated payload", body: frame(0, "message")[:frameHeaderSize+3], wantErr: "failed to read frame"},
+		{name: "truncated trailer frame", body: frame(0, "msg") + trailerFrame("grpc-status: 0\r\n")[:8], wantErr: "failed to read frame"},
+		{name: "compressed message", body: frame(flagCompressed, "msg"), wantErr: "compressed messages"},
+		{name: "more than one message", body: frame(0, "a") + frame(0, "b"), wantErr: "more than one message"},
+		{name: "frame exceeds limit", body: frame(0, "message"), maxSize: 6, wantErr: "exceeds limit"},
+		{name: "frame at limit", body: frame(0, "message"), maxSize: 7, wantMsg: "message"},
+		{
+			// Only the prefix is sent, the payload can't be allocated before it's rejected
+			name:    "huge prefix",
+			body:    "\x00\xff\xff\xff\xff",
+			wantErr: "exceeds limit",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxSize := tt.maxSize
+			if maxSize == 0 {
+				maxSize = DefaultMaxFrameSize
+			}
+			msg, trailers, err := readFrames(strings.NewReader(tt.body), maxSize)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(msg) != tt.wantMsg {
+				t.Errorf("got message %q, want %q", msg, tt.wantMsg)
+			}
+			if len(trailers) != len(tt.wantTrailers) {
+				t.Fatalf("got trailers %v, want %v", trailers, tt.wantTrailers)
+			}
+			for key := range tt.wantTrailers {
+				if got, want := trailers.Get(key), tt.wantTrailers.Get(key); got != want {
+					t.Errorf("got trailer %s %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   http.Header
+		wantCode Code
+		wantMsg  string
+		wantErr  bool
+	}{
+		{name: "ok", header: http.Header{"Grpc-Status": {"0"}}, wantCode: OK},
+		{name: "not found", header: http.Header{"Grpc-Status": {"5"}, "Grpc-Message": {"no such user"}}, wantCode: NotFound, wantMsg: "no such user", wantErr: true},
+		{name: "percent-encoded message", header: http.Header{"Grpc-Status": {"3"}, "Grpc-Message": {"bad%20id%3A%20%E2%9C%93"}}, wantCode: InvalidArgument, wantMsg: "bad id: ✓", wantErr: true},
+		{name: "padded status", header: http.Header{"Grpc-Status": {" 14 "}}, wantCode: Unavailable, wantErr: true},
+		{name: "unknown code", header: http.Header{"Grpc-Status": {"42"}}, wantCode: Code(42), wantErr: true},
+		{name: "missing status", header: http.Header{}, wantCode: Unknown, wantErr: true},
+		{name: "invalid status", header: http.Header{"Grpc-Status": {"abc"}}, wantCode: Unknown, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := statusError(tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got := CodeOf(err); got != tt.wantCode {
+				t.Errorf("got code %s, want %s", got, tt.wantCode)
+			}
+			var e *Error
+			if errors.As(err, &e) && e.Message != tt.wantMsg {
+				t.Errorf("got message %q, want %q", e.Message, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCodeString(t *testing.T) {
+	if got := Unauthenticated.String(); got != "Unauthenticated" {
+		t.Errorf("got %q, want %q", got, "Unauthenticated")
+	}
+	if got := Code(42).String(); got != "Code(42)" {
+		t.Errorf("got %q, want %q", got, "Code(42)")
+	}
+}
+
+func TestInvoke(t *testing.T) {
+	reply, err := proto.Marshal(wrapperspb.String("pong"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		opts     []clientx.RequestOption
+		status   int
+		header   http.Header
+		body     string
+		want     string
+		wantCode Code
+		wantErr  string
+	}{
+		{
+			name:   "message and OK trailer",
+			status: http.StatusOK,
+			body:   frame(0, string(reply)) + trailerFrame("grpc-status: 0\r\n"),
+			want:   "pong",
+		},
+		{
+			name:     "error in trailer",
+			status:   http.StatusOK,
+			body:     trailerFrame("grpc-status: 7\r\ngrpc-message: access%20denied\r\n"),
+			wantCode: PermissionDenied,
+			wantErr:  "access denied",
+		},
+		{
+			name:     "trailers-only response",
+			status:   http.StatusOK,
+			header:   http.Header{"Grpc-Status": {"12"}, "Grpc-Message": {"not implemented"}},
+			wantCode: Unimplemented,
+			wantErr:  "not implemented",
+		},
+		{
+			name:     "status in headers of HTTP error",
+			status:   http.StatusServiceUnavailable,
+			header:   http.Header{"Grpc-Status": {"14"}},
+			wantCode: Unavailable,
+			wantErr:  "Unavailable",
+		},
+		{
+			name:     "HTTP error without status",
+			status:   http.StatusBadGateway,
+			wantCode: Unknown,
+			wantErr:  "unexpected HTTP status 502",
+		},
+		{
+			name:     "OK without message",
+			status:   http.StatusOK,
+			body:     trailerFrame("grpc-status: 0\r\n"),
+			wantCode: Unknown,
+			wantErr:  "doesn't contain message",
+		},
+		{
+			name:     "truncated response",
+			status:   http.StatusOK,
+			body:     frame(0, string(reply))[:frameHeaderSize+1],
+			wantCode: Unknown,
+			wantErr:  "failed to read frame",
+		},
+		{
+			name:     "frame exceeds configured limit",
+			opts:     []clientx.RequestOption{WithMaxFrameSize(uint32(len(reply)) - 1)},
+			status:   http.StatusOK,
+			body:     frame(0, string(reply)) + trailerFrame("grpc-status: 0\r\n"),
+			wantCode: Unknown,
+			wantErr:  "exceeds limit",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotReq []byte
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if ct := r.Header.Get("Content-Type"); ct != ContentType {
+					t.Errorf("got Content-Type %q, want %q", ct, ContentType)
+				}
+				gotReq, _ = io.ReadAll(r.Body)
+				for key, vals := range tt.header {
+					w.Header()[key] = vals
+				}
+				w.Header().Set("Content-Type", ContentType)
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+
+			api := clientx.NewAPI(clientx.WithBaseURL(srv.URL))
+			resp, err := Invoke[wrapperspb.StringValue, wrapperspb.StringValue](context.Background(), api,
+				"/echo.EchoService/Echo", wrapperspb.String("ping"), tt.opts...)
+
+			wantReq, _ := proto.Marshal(wrapperspb.String("ping"))
+			if !bytes.Equal(gotReq, []byte(frame(0, string(wantReq)))) {
+				t.Errorf("got request body %q, want framed message", gotReq)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want error containing %q", err, tt.wantErr)
+				}
+				if got := CodeOf(err); got != tt.wantCode {
+					t.Errorf("got code %s, want %s", got, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.GetValue() != tt.want {
+				t.Errorf("got %q, want %q", resp.GetValue(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// OptionHedging configures hedged requests (see WithHedging).
+type OptionHedging struct {
+	// Delay is a time to wait for response before sending the next hedged request.
+	Delay time.Duration
+	// MaxHedges is a maximum number of hedged requests sent in addition to the original one.
+	MaxHedges int
+}
+
+// WithHedging enables hedged requests to reduce tail latency: if response to request hasn't been received
+// within delay, an identical request is sent (at most maxHedges times, each after another delay) and whichever
+// response comes first is used, other requests are cancelled. Only idempotent requests are hedged
+// (GET, HEAD, OPTIONS, TRACE, PUT, DELETE and requests with Idempotency-Key header) if their body can be replayed.
+// Hedged requests wait for rate limiter and consume its tokens as regular ones.
+func WithHedging(delay time.Duration, maxHedges int) Option {
+	return func(o *Options) {
+		o.Hedging = &OptionHedging{
+			Delay:     delay,
+			MaxHedges: maxHedges,
+		}
+	}
+}
+
+type hedgeResult struct {
+	// i is an index of request, 0 is the original one.
+	i      int
+	resp   *http.Response
+	err    error
+	cancel context.CancelFunc
+}
+
+// doHedged performs request with hedging if it's enabled and request can be hedged, otherwise performs it as is.
+// The wait is called before sending of every hedged request, req itself must be already allowed by limiter.
+func (api *API) doHedged(httpClient *http.Client, req *http.Request, wait func(ctx context.Context, req *http.Request) error) (*http.Response, error) {
+	h := api.options.Hedging
+	if h == nil || h.Delay <= 0 || h.MaxHedges <= 0 || !isIdempotent(req) || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return httpClient.Do(req)
+	}
+
+	results := make(chan hedgeResult, h.MaxHedges+1)
+	var cancels []context.CancelFunc
+	launch := func(r *http.Request, hedge bool) {
+		ctx, cancel := context.WithCancel(r.Context())
+		i := len(cancels)
+		cancels = append(cancels, cancel)
+		r = r.WithContext(ctx)
+		go func() {
+			if hedge {
+				if err := wait(ctx, r); err != nil {
+					results <- hedgeResult{i: i, err: err, cancel: cancel}
+					return
+				}
+			}
+			resp, err := httpClient.Do(r)
+			results <- hedgeResult{i: i, resp: resp, err: err, cancel: cancel}
+		}()
+	}
+
+	launch(req, false)
+	inflight := 1
+	timer := time.NewTimer(h.Delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			hedgeReq, err := cloneRequestBody(req)
+			if err != nil {
+				// Body can't be reopened, stop hedging and keep waiting for requests in flight
+				api.logger().Warn("hedging is stopped, failed to reopen request body", "error", err)
+				continue
+			}
+			launch(hedgeReq, true)
+			inflight++
+			if len(cancels) <= h.MaxHedges {
+				timer.Reset(h.Delay)
+			}
+		case r := <-results:
+			inflight--
+			if r.err != nil {
+				r.cancel()
+				if inflight == 0 {
+					return nil, r.err
+				}
+				continue
+			}
+			for i, cancel := range cancels {
+				if i != r.i {
+					cancel() // context of the winner is cancelled when its body is closed
+				}
+			}
+			go discardHedges(results, inflight)
+			r.resp.Body = cancelReadCloser{r.resp.Body, r.cancel}
+			return r.resp, nil
+		}
+	}
+}
+
+// discardHedges closes responses of cancelled hedged requests which are still in flight.
+func discardHedges(results <-chan hedgeResult, n int) {
+	for ; n > 0; n-- {
+		r := <-results
+		if r.resp != nil {
+			r.resp.Body.Close()
+		}
+		r.cancel()
+	}
+}
+
+// cloneRequestBody returns copy of request with reopened body.
+func cloneRequestBody(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.GetBody != nil && req.Body != nil && req.Body != http.NoBody {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Body = body
+	}
+	return clone, nil
+}
+
+// isIdempotent reports whether request can be safely sent several times.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return req.Header.Get(IdempotencyKeyHeader) != ""
+	}
+}
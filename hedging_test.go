@@ -0,0 +1,150 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedgingWinner(t *testing.T) {
+	var calls int64
+	cancelled := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&calls, 1) == 1 {
+			// The original request is slow, it has to be cancelled once the hedge wins
+			select {
+			case <-r.Context().Done():
+				close(cancelled)
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		io.WriteString(w, "hedge")
+	}))
+	defer srv.Close()
+
+	api := NewAPI(WithBaseURL(srv.URL), WithHedging(10*time.Millisecond, 1))
+	start := time.Now()
+	resp, err := NewRequestBuilder[Empty, Empty](api).Get("/").Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "hedge" {
+		t.Errorf("got body %q, want response of the hedged request", body)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("request returned after %v, want response of the hedged request", elapsed)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Error("losing request isn't cancelled")
+	}
+}
+
+func TestHedgingMaxHedges(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxHedges int
+		wantCalls int64
+	}{
+		{"single hedge", 1, 2},
+		{"several hedges", 3, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int64
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt64(&calls, 1)
+				select {
+				case <-r.Context().Done():
+				case <-time.After(200 * time.Millisecond):
+				}
+			}))
+			defer srv.Close()
+
+			api := NewAPI(WithBaseURL(srv.URL), WithHedging(5*time.Millisecond, tt.maxHedges))
+			resp, err := NewRequestBuilder[Empty, Empty](api).Get("/").Do(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if n := atomic.LoadInt64(&calls); n != tt.wantCalls {
+				t.Errorf("server got %d requests, want %d", n, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestHedgingNotReplayableBody(t *testing.T) {
+	nonReplayable := func(req *http.Request) error {
+		req.Body = io.NopCloser(strings.NewReader("payload"))
+		req.GetBody = nil
+		req.ContentLength = int64(len("payload"))
+		return nil
+	}
+	failingGetBody := func(req *http.Request) error {
+		req.Body = io.NopCloser(strings.NewReader("payload"))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return nil, errors.New("body is gone")
+		}
+		req.ContentLength = int64(len("payload"))
+		return nil
+	}
+	tests := []struct {
+		name    string
+		opt     RequestOption
+		wantLog string
+	}{
+		{"body without GetBody isn't hedged", nonReplayable, ""},
+		{"hedging is stopped if GetBody fails", failingGetBody, "hedging is stopped"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int64
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt64(&calls, 1)
+				if b, _ := io.ReadAll(r.Body); string(b) != "payload" {
+					t.Errorf("got body %q, want %q", b, "payload")
+				}
+				time.Sleep(50 * time.Millisecond)
+			}))
+			defer srv.Close()
+
+			var logs bytes.Buffer
+			api := NewAPI(
+				WithBaseURL(srv.URL),
+				WithHedging(5*time.Millisecond, 3),
+				WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+			)
+			resp, err := NewRequestBuilder[Empty, Empty](api).Put("/", nil, tt.opt).Do(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if n := atomic.LoadInt64(&calls); n != 1 {
+				t.Errorf("server got %d requests, want 1", n)
+			}
+			if tt.wantLog != "" && !strings.Contains(logs.String(), tt.wantLog) {
+				t.Errorf("got logs %q, want %q", logs.String(), tt.wantLog)
+			}
+		})
+	}
+}
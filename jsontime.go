@@ -0,0 +1,197 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is a time.Duration which can be decoded from JSON string in ISO 8601 format ("PT1H30M", "P1DT2H"),
+// Go format ("1h30m") or from JSON number of seconds. Years and months aren't supported, because their
+// length is ambiguous. Duration is encoded in ISO 8601 format.
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(b, []byte("null")) {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		secs, err := strconv.ParseFloat(string(b), 64)
+		if err != nil {
+			return fmt.Errorf("invalid duration %s", b)
+		}
+		d.Duration = time.Duration(secs * float64(time.Second))
+		return nil
+	}
+	v, err := ParseISO8601Duration(s)
+	if err != nil {
+		var goErr error
+		if v, goErr = time.ParseDuration(s); goErr != nil {
+			return err
+		}
+	}
+	d.Duration = v
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(FormatISO8601Duration(d.Duration))
+}
+
+// ParseISO8601Duration parses duration in ISO 8601 format, e.g. "PT1H30M", "P1DT12H", "PT0.5S" or "-P2W".
+// A day is 24 hours and a week is 7 days, years and months aren't supported. Designators must be
+// in order (W, D, then H, M, S after T) and each of them may appear at most once.
+func ParseISO8601Duration(s string) (time.Duration, error) {
+	orig := s
+	var neg bool
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	if len(s) < 2 || s[0] != 'P' {
+		return 0, fmt.Errorf("invalid ISO 8601 duration %q", orig)
+	}
+	s = s[1:]
+
+	var (
+		d      time.Duration
+		inTime bool
+		last   int // rank of the last parsed designator
+	)
+	for s != "" {
+		if s[0] == 'T' {
+			if inTime || len(s) == 1 {
+				return 0, fmt.Errorf("invalid ISO 8601 duration %q", orig)
+			}
+			inTime = true
+			s = s[1:]
+			continue
+		}
+		i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' && r != ',' })
+		if i <= 0 {
+			return 0, fmt.Errorf("invalid ISO 8601 duration %q", orig)
+		}
+		v, err := strconv.ParseFloat(strings.Replace(s[:i], ",", ".", 1), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid ISO 8601 duration %q: %w", orig, err)
+		}
+
+		var (
+			unit time.Duration
+			rank int
+		)
+		switch designator := s[i]; {
+		case !inTime && designator == 'W':
+			unit, rank = 7*24*time.Hour, 1
+		case !inTime && designator == 'D':
+			unit, rank = 24*time.Hour, 2
+		case !inTime && (designator == 'Y' || designator == 'M'):
+			return 0, fmt.Errorf("ISO 8601 duration %q has years or months of ambiguous length", orig)
+		case inTime && designator == 'H':
+			unit, rank = time.Hour, 3
+		case inTime && designator == 'M':
+			unit, rank = time.Minute, 4
+		case inTime && designator == 'S':
+			unit, rank = time.Second, 5
+		default:
+			return 0, fmt.Errorf("invalid ISO 8601 duration %q", orig)
+		}
+		if rank <= last {
+			return 0, fmt.Errorf("invalid ISO 8601 duration %q: designator %c is out of order or repeated", orig, s[i])
+		}
+		last = rank
+
+		part := v * float64(unit)
+		if part >= math.MaxInt64 || time.Duration(part) > math.MaxInt64-d {
+			return 0, fmt.Errorf("ISO 8601 duration %q overflows time.Duration", orig)
+		}
+		d += time.Duration(part)
+		s = s[i+1:]
+	}
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
+
+// FormatISO8601Duration formats d in ISO 8601 format with hours, minutes and seconds, e.g. "PT1H30M".
+func FormatISO8601Duration(d time.Duration) string {
+	if d == 0 {
+		return "PT0S"
+	}
+	var b strings.Builder
+	if d < 0 {
+		b.WriteByte('-')
+		d = -d
+	}
+	b.WriteString("PT")
+	if h := d / time.Hour; h != 0 {
+		b.WriteString(strconv.FormatInt(int64(h), 10) + "H")
+		d -= h * time.Hour
+	}
+	if m := d / time.Minute; m != 0 {
+		b.WriteString(strconv.FormatInt(int64(m), 10) + "M")
+		d -= m * time.Minute
+	}
+	if d != 0 {
+		b.WriteString(strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "S")
+	}
+	return b.String()
+}
+
+// Time is a time.Time which can be decoded from JSON string in RFC 3339 and common ISO 8601 layouts
+// (offset without colon, no time zone, date only) or from JSON number of Unix seconds.
+// Empty string and null are decoded into zero time. Time is encoded in RFC 3339 format.
+type Time struct {
+	time.Time
+}
+
+// timeLayouts are layouts tried in order to decode Time. Layouts without time zone are parsed in UTC.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700", // ISO 8601 offset without colon
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+func (t *Time) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(b, []byte("null")) {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		secs, err := strconv.ParseFloat(string(b), 64)
+		if err != nil {
+			return fmt.Errorf("invalid time %s", b)
+		}
+		t.Time = time.Unix(0, int64(secs*float64(time.Second))).UTC()
+		return nil
+	}
+	if s == "" {
+		t.Time = time.Time{}
+		return nil
+	}
+	for _, layout := range timeLayouts {
+		if v, err := time.Parse(layout, s); err == nil {
+			t.Time = v
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid time %q", s)
+}
+
+func (t Time) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Time.Format(time.RFC3339Nano))
+}
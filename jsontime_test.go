@@ -0,0 +1,102 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseISO8601Duration(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "seconds", s: "PT30S", want: 30 * time.Second},
+		{name: "hours and minutes", s: "PT1H30M", want: 90 * time.Minute},
+		{name: "all time designators", s: "PT1H2M3S", want: time.Hour + 2*time.Minute + 3*time.Second},
+		{name: "days and hours", s: "P1DT12H", want: 36 * time.Hour},
+		{name: "days only", s: "P2D", want: 48 * time.Hour},
+		{name: "weeks", s: "P2W", want: 14 * 24 * time.Hour},
+		{name: "weeks and days", s: "P1W1D", want: 8 * 24 * time.Hour},
+		{name: "zero", s: "PT0S", want: 0},
+		{name: "fractional seconds", s: "PT0.5S", want: 500 * time.Millisecond},
+		{name: "fractional seconds with comma", s: "PT1,25S", want: 1250 * time.Millisecond},
+		{name: "fractional hours", s: "PT1.5H", want: 90 * time.Minute},
+		{name: "negative", s: "-PT1M", want: -time.Minute},
+		{name: "negative weeks", s: "-P2W", want: -14 * 24 * time.Hour},
+		{name: "explicit positive", s: "+PT1M", want: time.Minute},
+		{name: "near limit", s: "PT2562047H", want: 2562047 * time.Hour},
+
+		{name: "empty", s: "", wantErr: true},
+		{name: "designator only", s: "P", wantErr: true},
+		{name: "without P", s: "T1H", wantErr: true},
+		{name: "sign only", s: "-", wantErr: true},
+		{name: "empty time part", s: "PT", wantErr: true},
+		{name: "trailing T", s: "P1DT", wantErr: true},
+		{name: "repeated T", s: "PT1HT1M", wantErr: true},
+		{name: "time designator without T", s: "P1H", wantErr: true},
+		{name: "date designator after T", s: "PT1D", wantErr: true},
+		{name: "years", s: "P1Y", wantErr: true},
+		{name: "months", s: "P1M", wantErr: true},
+		{name: "missing number", s: "PTH", wantErr: true},
+		{name: "missing designator", s: "PT1", wantErr: true},
+		{name: "unknown designator", s: "PT1X", wantErr: true},
+		{name: "invalid number", s: "PT1.2.3S", wantErr: true},
+		{name: "Go format", s: "1h30m", wantErr: true},
+		{name: "out of order time designators", s: "PT1S1H", wantErr: true},
+		{name: "out of order date designators", s: "P1D1W", wantErr: true},
+		{name: "repeated hours", s: "PT1H1H", wantErr: true},
+		{name: "repeated days", s: "P1D2D", wantErr: true},
+		{name: "overflowing component", s: "PT2562048H", wantErr: true},
+		{name: "overflowing weeks", s: "P100000W", wantErr: true},
+		{name: "overflowing sum", s: "PT2562047H60M", wantErr: true},
+		{name: "number out of float range", s: "PT" + strings.Repeat("9", 400) + "S", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseISO8601Duration(tt.s)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseISO8601Duration(%q) = %v, want error", tt.s, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseISO8601Duration(%q) = %v, want %v", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatISO8601Duration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "PT0S"},
+		{90 * time.Minute, "PT1H30M"},
+		{36 * time.Hour, "PT36H"},
+		{1500 * time.Millisecond, "PT1.5S"},
+		{-time.Minute, "-PT1M"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got := FormatISO8601Duration(tt.d)
+			if got != tt.want {
+				t.Errorf("FormatISO8601Duration(%v) = %q, want %q", tt.d, got, tt.want)
+			}
+			back, err := ParseISO8601Duration(got)
+			if err != nil || back != tt.d {
+				t.Errorf("ParseISO8601Duration(%q) = (%v, %v), want %v", got, back, err, tt.d)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// WithRequestLogging enables logging of one structured record with info level per completed request:
+// method, path, status, duration, attempts and response size. Bodies are never logged,
+// so unlike WithDebug it's safe to use in production. Attempts equals 0 when response was served from cache.
+func WithRequestLogging(logger *slog.Logger) Option {
+	return func(o *Options) {
+		o.RequestLogger = logger
+	}
+}
+
+func (api *API) logRequest(req *http.Request, resp *http.Response, err error, duration time.Duration, attempts int, bytes int) {
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("path", req.URL.Path),
+		slog.Duration("duration", duration),
+		slog.Int("attempts", attempts),
+	}
+	if id := RequestIDFromContext(req.Context()); id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	if resp != nil {
+		attrs = append(attrs, slog.Int("status", resp.StatusCode), slog.Int("bytes", bytes))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	api.options.RequestLogger.LogAttrs(req.Context(), slog.LevelInfo, "http request", attrs...)
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// MergeJSON deep-merges override into base and returns resulting JSON object. Both values are encoded
+// as JSON objects first (structs, maps, json.RawMessage), so struct tags are respected. Only non-zero values
+// of override are merged (null, false, 0, "", empty arrays and objects are skipped), nested objects
+// are merged recursively, other values (including arrays) replace values of base.
+//
+// It's useful for partial updates (PATCH), when the body is built from a base object with overrides
+// and server mustn't receive unintended zero values.
+func MergeJSON(base, override any) ([]byte, error) {
+	dst, err := jsonObject(base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode base: %w", err)
+	}
+	src, err := jsonObject(override)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode override: %w", err)
+	}
+	mergeJSONObjects(dst, src)
+	return json.Marshal(dst)
+}
+
+// PatchMerge builds PATCH request with JSON body produced by MergeJSON of base and override.
+// Use Empty as Req type. Appends request options.
+func (rb *RequestBuilder[Req, Resp]) PatchMerge(path string, base, override any, opts ...RequestOption) *RequestBuilder[Req, Resp] {
+	rb.method = http.MethodPatch
+	rb.resourcePath = path
+	rb.jsonBody = jsonMerge{base: base, override: override}
+	rb.requestOptions = append(rb.requestOptions, opts...)
+	return rb
+}
+
+// jsonMerge is a request body merged when it's encoded.
+type jsonMerge struct {
+	base, override any
+}
+
+func (m jsonMerge) MarshalJSON() ([]byte, error) {
+	return MergeJSON(m.base, m.override)
+}
+
+// jsonObject encodes v into JSON object, nil value is an empty object.
+func jsonObject(v any) (map[string]any, error) {
+	obj := make(map[string]any)
+	if v == nil {
+		return obj, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(b, []byte("null")) {
+		return obj, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber() // keep precision of numbers
+	if err := dec.Decode(&obj); err != nil {
+		return nil, fmt.Errorf("value %T isn't a JSON object: %w", v, err)
+	}
+	return obj, nil
+}
+
+func mergeJSONObjects(dst, src map[string]any) {
+	for key, val := range src {
+		if isZeroJSON(val) {
+			continue
+		}
+		if obj, ok := val.(map[string]any); ok {
+			if dstObj, ok := dst[key].(map[string]any); ok {
+				mergeJSONObjects(dstObj, obj)
+				continue
+			}
+			merged := make(map[string]any)
+			mergeJSONObjects(merged, obj)
+			if len(merged) == 0 {
+				continue
+			}
+			val = merged
+		}
+		dst[key] = val
+	}
+}
+
+func isZeroJSON(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	case string:
+		return v == ""
+	case json.Number:
+		f, err := strconv.ParseFloat(v.String(), 64)
+		return err == nil && f == 0
+	case []any:
+		return len(v) == 0
+	case map[string]any:
+		return len(v) == 0
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestMergeJSON(t *testing.T) {
+	type address struct {
+		City string `json:"city,omitempty"`
+		Zip  string `json:"zip,omitempty"`
+	}
+	type user struct {
+		Name    string   `json:"name"`
+		Age     int      `json:"age"`
+		Active  bool     `json:"active"`
+		Tags    []string `json:"tags"`
+		Address *address `json:"address"`
+	}
+	tests := []struct {
+		name     string
+		base     any
+		override any
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "scalars are replaced",
+			base:     json.RawMessage(`{"name":"alice","age":30}`),
+			override: json.RawMessage(`{"age":31}`),
+			want:     `{"name":"alice","age":31}`,
+		},
+		{
+			name:     "new keys are added",
+			base:     json.RawMessage(`{"name":"alice"}`),
+			override: json.RawMessage(`{"email":"a@example.com"}`),
+			want:     `{"name":"alice","email":"a@example.com"}`,
+		},
+		{
+			name:     "nested objects are merged",
+			base:     json.RawMessage(`{"address":{"city":"Kyiv","zip":"01001"},"name":"alice"}`),
+			override: json.RawMessage(`{"address":{"zip":"02000","street":"Main"}}`),
+			want:     `{"address":{"city":"Kyiv","zip":"02000","street":"Main"},"name":"alice"}`,
+		},
+		{
+			name:     "deeply nested objects are merged",
+			base:     json.RawMessage(`{"a":{"b":{"c":1,"d":2}}}`),
+			override: json.RawMessage(`{"a":{"b":{"d":3}}}`),
+			want:     `{"a":{"b":{"c":1,"d":3}}}`,
+		},
+		{
+			name:     "object replaces scalar",
+			base:     json.RawMessage(`{"address":"unknown"}`),
+			override: json.RawMessage(`{"address":{"city":"Kyiv"}}`),
+			want:     `{"address":{"city":"Kyiv"}}`,
+		},
+		{
+			name:     "arrays are replaced, not merged",
+			base:     json.RawMessage(`{"tags":["a","b","c"]}`),
+			override: json.RawMessage(`{"tags":["d"]}`),
+			want:     `{"tags":["d"]}`,
+		},
+		{
+			name:     "arrays of objects are replaced",
+			base:     json.RawMessage(`{"items":[{"id":1},{"id":2}]}`),
+			override: json.RawMessage(`{"items":[{"name":"x"}]}`),
+			want:     `{"items":[{"name":"x"}]}`,
+		},
+		{
+			name:     "null doesn't override",
+			base:     json.RawMessage(`{"name":"alice","address":{"city":"Kyiv"}}`),
+			override: json.RawMessage(`{"name":null,"address":null}`),
+			want:     `{"name":"alice","address":{"city":"Kyiv"}}`,
+		},
+		{
+			name:     "null of base is overridden",
+			base:     json.RawMessage(`{"name":null}`),
+			override: json.RawMessage(`{"name":"bob"}`),
+			want:     `{"name":"bob"}`,
+		},
+		{
+			name:     "zero values don't override",
+			base:     json.RawMessage(`{"name":"alice","age":30,"active":true,"tags":["a"],"meta":{"k":"v"}}`),
+			override: json.RawMessage(`{"name":"","age":0,"active":false,"tags":[],"meta":{}}`),
+			want:     `{"name":"alice","age":30,"active":true,"tags":["a"],"meta":{"k":"v"}}`,
+		},
+		{
+			name:     "nested object of zero values isn't added",
+			base:     json.RawMessage(`{"name":"alice"}`),
+			override: json.RawMessage(`{"address":{"city":"","zip":null}}`),
+			want:     `{"name":"alice"}`,
+		},
+		{
+			name:     "large numbers keep precision",
+			base:     json.RawMessage(`{"id":1}`),
+			override: json.RawMessage(`{"id":9007199254740993}`),
+			want:     `{"id":9007199254740993}`,
+		},
+		{
+			name:     "structs",
+			base:     user{Name: "alice", Age: 30, Active: true, Tags: []string{"a"}, Address: &address{City: "Kyiv"}},
+			override: user{Age: 31, Address: &address{Zip: "01001"}},
+			want:     `{"name":"alice","age":31,"active":true,"tags":["a"],"address":{"city":"Kyiv","zip":"01001"}}`,
+		},
+		{
+			name:     "maps",
+			base:     map[string]any{"name": "alice", "age": 30},
+			override: map[string]any{"name": "bob"},
+			want:     `{"name":"bob","age":30}`,
+		},
+		{
+			name:     "nil base",
+			base:     nil,
+			override: json.RawMessage(`{"name":"bob"}`),
+			want:     `{"name":"bob"}`,
+		},
+		{
+			name:     "nil override",
+			base:     json.RawMessage(`{"name":"alice"}`),
+			override: nil,
+			want:     `{"name":"alice"}`,
+		},
+		{
+			name:     "null base",
+			base:     json.RawMessage(`null`),
+			override: json.RawMessage(`{"name":"bob"}`),
+			want:     `{"name":"bob"}`,
+		},
+		{name: "invalid base", base: json.RawMessage(`{"name":`), override: nil, wantErr: true},
+		{name: "invalid override", base: nil, override: json.RawMessage(`{name: "bob"}`), wantErr: true},
+		{name: "array base", base: json.RawMessage(`[1,2]`), override: nil, wantErr: true},
+		{name: "scalar override", base: nil, override: "bob", wantErr: true},
+		{name: "not encodable", base: map[string]any{"f": func() {}}, override: nil, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergeJSON(tt.base, tt.override)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %s, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSONEqual(t, got, tt.want)
+		})
+	}
+}
+
+func TestPatchMerge(t *testing.T) {
+	api := NewAPI(WithBaseURL("https://example.com"))
+	req, err := NewRequestBuilder[Empty, Empty](api).
+		PatchMerge("/users/1", json.RawMessage(`{"name":"alice","age":30}`), map[string]any{"age": 31, "name": ""}).
+		Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != http.MethodPatch {
+		t.Errorf("got method %s, want %s", req.Method, http.MethodPatch)
+	}
+	b, err := requestBody(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSONEqual(t, b, `{"name":"alice","age":31}`)
+}
+
+// assertJSONEqual compares JSON documents regardless of keys order and formatting.
+func assertJSONEqual(t *testing.T, got []byte, want string) {
+	t.Helper()
+	decode := func(b []byte) any {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber() // compare numbers exactly
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			t.Fatalf("invalid JSON %s: %v", b, err)
+		}
+		return v
+	}
+	g, w := decode(got), decode([]byte(want))
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("got JSON %s, want %s", got, want)
+	}
+}
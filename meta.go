@@ -0,0 +1,168 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ResponseMeta contains typed values of common response headers.
+type ResponseMeta struct {
+	// RetryAfter is a wait duration from Retry-After header (either delay-seconds or HTTP-date form).
+	// Zero if the header is missing, malformed or contains a date in the past, delays over 24 hours are clamped.
+	RetryAfter time.Duration
+	// HasRetryAfter is true if Retry-After header is present and valid.
+	HasRetryAfter bool
+	// RateLimit contains values of X-RateLimit-* headers.
+	RateLimit ResponseRateLimit
+	// ContentRange contains value of Content-Range header.
+	ContentRange *ContentRange
+	// Links maps relation types to URLs from Link header, e.g. "next" => "https://api.example.com/items?page=2".
+	Links map[string]string
+}
+
+// ResponseRateLimit contains values of X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset headers.
+// Missing values are set to -1 (Limit, Remaining) and zero time (Reset).
+type ResponseRateLimit struct {
+	Limit     int
+	Remaining int
+	// Reset is parsed either as Unix timestamp or as number of seconds from now (when value is small).
+	Reset time.Time
+}
+
+// ContentRange is a parsed Content-Range header: bytes Start-End/Size. Size is -1 if unknown (*).
+type ContentRange struct {
+	Start int64
+	End   int64
+	Size  int64
+}
+
+// ParseResponseMeta parses common headers of resp into ResponseMeta.
+func ParseResponseMeta(resp *http.Response) ResponseMeta {
+	meta := ResponseMeta{
+		RateLimit: parseRateLimitHeaders(resp.Header),
+		Links:     parseLinkHeader(resp.Header.Values("Link")),
+	}
+	meta.RetryAfter, meta.HasRetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	if cr, ok := parseContentRange(resp.Header.Get("Content-Range")); ok {
+		meta.ContentRange = &cr
+	}
+	return meta
+}
+
+// maxRetryAfter is a maximum delay parsed from Retry-After header, larger delays are clamped to it.
+const maxRetryAfter = 24 * time.Hour
+
+// parseRetryAfter parses Retry-After header value, which is either delay in seconds or HTTP-date.
+// Dates in the past are treated as zero wait, delays are clamped to maxRetryAfter.
+func parseRetryAfter(s string, now time.Time) (time.Duration, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil || errors.Is(err, strconv.ErrRange) {
+		if secs < 0 {
+			return 0, false
+		}
+		if secs > int64(maxRetryAfter/time.Second) {
+			return maxRetryAfter, true // multiplication would overflow
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	at, err := http.ParseTime(s)
+	if err != nil {
+		return 0, false
+	}
+	wait := at.Sub(now)
+	if wait <= 0 {
+		return 0, true
+	}
+	return min(wait, maxRetryAfter), true
+}
+
+// resetAsDeltaThreshold is a boundary between "seconds from now" and "Unix timestamp" forms of X-RateLimit-Reset.
+const resetAsDeltaThreshold = 365 * 24 * 60 * 60
+
+func parseRateLimitHeaders(h http.Header) ResponseRateLimit {
+	rl := ResponseRateLimit{Limit: -1, Remaining: -1}
+	if v, err := strconv.Atoi(h.Get("X-RateLimit-Limit")); err == nil {
+		rl.Limit = v
+	}
+	if v, err := strconv.Atoi(h.Get("X-RateLimit-Remaining")); err == nil {
+		rl.Remaining = v
+	}
+	if v, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		if v < resetAsDeltaThreshold {
+			rl.Reset = time.Now().Add(time.Duration(v) * time.Second)
+		} else {
+			rl.Reset = time.Unix(v, 0)
+		}
+	}
+	return rl
+}
+
+func parseContentRange(s string) (ContentRange, bool) {
+	s, ok := strings.CutPrefix(strings.TrimSpace(s), "bytes ")
+	if !ok {
+		return ContentRange{}, false
+	}
+	rng, size, ok := strings.Cut(s, "/")
+	if !ok {
+		return ContentRange{}, false
+	}
+	cr := ContentRange{Start: -1, End: -1, Size: -1}
+	if size != "*" {
+		v, err := strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			return ContentRange{}, false
+		}
+		cr.Size = v
+	}
+	if rng == "*" {
+		// Unsatisfied range: bytes */size
+		return cr, true
+	}
+	start, end, ok := strings.Cut(rng, "-")
+	if !ok {
+		return ContentRange{}, false
+	}
+	var err error
+	if cr.Start, err = strconv.ParseInt(start, 10, 64); err != nil {
+		return ContentRange{}, false
+	}
+	if cr.End, err = strconv.ParseInt(end, 10, 64); err != nil {
+		return ContentRange{}, false
+	}
+	return cr, true
+}
+
+// parseLinkHeader parses Link header (RFC 8288): <https://api.example.com/items?page=2>; rel="next".
+func parseLinkHeader(values []string) map[string]string {
+	links := make(map[string]string)
+	for _, v := range values {
+		for _, link := range strings.Split(v, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			target = target[1 : len(target)-1]
+			for _, param := range parts[1:] {
+				name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(strings.TrimSpace(name), "rel") {
+					continue
+				}
+				// rel may contain several space-separated relation types
+				for _, rel := range strings.Fields(strings.Trim(value, `"`)) {
+					links[strings.ToLower(rel)] = target
+				}
+			}
+		}
+	}
+	return links
+}
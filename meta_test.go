@@ -0,0 +1,46 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"missing", "", 0, false},
+		{"delta-seconds", "120", 2 * time.Minute, true},
+		{"zero delta-seconds", "0", 0, true},
+		{"padded delta-seconds", " 5 ", 5 * time.Second, true},
+		{"HTTP-date", now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{"RFC 850 date", now.Add(time.Minute).Format(time.RFC850), time.Minute, true},
+		{"past date", now.Add(-time.Hour).Format(http.TimeFormat), 0, true},
+		{"current date", now.Format(http.TimeFormat), 0, true},
+		{"negative", "-5", 0, false},
+		{"fraction", "1.5", 0, false},
+		{"garbage", "soon", 0, false},
+		{"date without timezone", "2024-01-02 15:05:05", 0, false},
+		{"overflowing delta-seconds", "9223372037", maxRetryAfter, true},
+		{"out of int64 range delta-seconds", "99999999999999999999", maxRetryAfter, true},
+		{"out of int64 range negative delta-seconds", "-99999999999999999999", 0, false},
+		{"far future date", now.AddDate(100, 0, 0).Format(http.TimeFormat), maxRetryAfter, true},
+		{"delta-seconds at limit", "86400", maxRetryAfter, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value, now)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("parseRetryAfter(%q) = (%v, %v), want (%v, %v)", tt.value, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// ErrNotMultipart is returned by DoMultipart when response isn't a multipart message.
+var ErrNotMultipart = errors.New("response is not multipart")
+
+// Part is a decoded part of multipart response.
+type Part[Resp any] struct {
+	// StatusCode is a status of sub-response if part is an embedded HTTP response (application/http),
+	// otherwise it is a status of the whole response.
+	StatusCode int
+	// Header contains headers of sub-response for application/http parts, otherwise headers of the part itself.
+	Header http.Header
+	// ContentID is a value of Content-ID header of the part, used by batch APIs to match sub-requests.
+	ContentID string
+	Decoded   *Resp
+	// Err is an error of reading or decoding of the part. It doesn't stop iteration over other parts.
+	Err error
+}
+
+// DoMultipart executes request and decodes multipart response (e.g. multipart/mixed of Google-style batch
+// endpoints) part by part. The boundary is taken from response Content-Type. Parts with application/http
+// content type are parsed as embedded HTTP responses and their bodies are decoded, other parts are decoded as is.
+// Empty bodies are left undecoded. Decoding errors are reported per part in Part.Err.
+func (rb *RequestBuilder[Req, Resp]) DoMultipart(ctx context.Context, enc ...EncoderDecoder) (_ []Part[Resp], err error) {
+	e, err := rb.client.api.selectEncoderDecoder(enc)
+	if err != nil {
+		rb.finish(nil, err, RetryStats{})
+		return nil, err
+	}
+
+	ctx, cancel := rb.client.api.operationContext(ctx)
+	defer cancel()
+
+	httpResp, reader, stats, err := rb.client.send(ctx, rb, e, 0, false)
+	defer func() {
+		rb.finish(httpResp, err, stats)
+	}()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	if rb.errDecodeFn != nil {
+		if ok, err := rb.errDecodeFn(httpResp); ok {
+			return nil, err
+		}
+	}
+	if err := rb.client.api.checkStatus(httpResp, reader); err != nil {
+		return nil, err
+	}
+	if err := rb.checkContentType(httpResp, reader); err != nil {
+		return nil, err
+	}
+
+	mediaType, params, err := mime.ParseMediaType(httpResp.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, fmt.Errorf("%w: %q", ErrNotMultipart, httpResp.Header.Get("Content-Type"))
+	}
+	if params["boundary"] == "" {
+		return nil, fmt.Errorf("%w: missing boundary", ErrNotMultipart)
+	}
+
+	var parts []Part[Resp]
+	mr := multipart.NewReader(reader, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return parts, nil
+		}
+		if err != nil {
+			return parts, err
+		}
+		parts = append(parts, decodePart[Resp](e, httpResp.StatusCode, p))
+		p.Close()
+	}
+}
+
+func decodePart[Resp any](dec Decoder, statusCode int, p *multipart.Part) Part[Resp] {
+	part := Part[Resp]{
+		StatusCode: statusCode,
+		Header:     http.Header(p.Header),
+		ContentID:  p.Header.Get("Content-ID"),
+	}
+
+	var body io.Reader = p
+	if mediaType, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type")); mediaType == "application/http" {
+		resp, err := http.ReadResponse(bufio.NewReader(p), nil)
+		if err != nil {
+			part.Err = fmt.Errorf("failed to read embedded response: %w", err)
+			return part
+		}
+		defer resp.Body.Close()
+		part.StatusCode = resp.StatusCode
+		part.Header = resp.Header
+		body = resp.Body
+	}
+
+	b, err := io.ReadAll(body)
+	if err != nil {
+		part.Err = err
+		return part
+	}
+	var decoded Resp
+	if len(b) != 0 {
+		if err := dec.Decode(bytes.NewReader(b), &decoded); err != nil {
+			part.Err = err
+			return part
+		}
+	}
+	part.Decoded = &decoded
+	return part
+}
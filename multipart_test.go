@@ -0,0 +1,152 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+)
+
+func TestDoMultipart(t *testing.T) {
+	type item struct {
+		ID int `json:"id"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mw := multipart.NewWriter(w)
+		w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+		parts := []struct {
+			header textproto.MIMEHeader
+			body   string
+		}{
+			{
+				header: textproto.MIMEHeader{"Content-Type": {"application/json"}, "Content-Id": {"<item-1>"}},
+				body:   `{"id":1}`,
+			},
+			{
+				header: textproto.MIMEHeader{"Content-Type": {"application/http"}, "Content-Id": {"<item-2>"}},
+				body:   "HTTP/1.1 201 Created\r\nContent-Type: application/json\r\nX-Sub: 2\r\n\r\n{\"id\":2}",
+			},
+			{
+				header: textproto.MIMEHeader{"Content-Type": {"application/http"}, "Content-Id": {"<item-3>"}},
+				body:   "HTTP/1.1 404 Not Found\r\nContent-Length: 0\r\n\r\n",
+			},
+			{
+				header: textproto.MIMEHeader{
+					"Content-Type":        {"application/json; charset=utf-8"},
+					"Content-Disposition": {`attachment; name="item"; filename="item-4.json"`},
+				},
+				body: `{"id":4}`,
+			},
+			{
+				header: textproto.MIMEHeader{"Content-Type": {"application/json"}},
+				body:   `{"id":`,
+			},
+			{
+				header: textproto.MIMEHeader{"Content-Type": {"application/http"}},
+				body:   "not an HTTP response",
+			},
+		}
+		for _, p := range parts {
+			pw, err := mw.CreatePart(p.header)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			io.WriteString(pw, p.body)
+		}
+		mw.Close()
+	}))
+	defer srv.Close()
+
+	api := NewAPI(WithBaseURL(srv.URL))
+	parts, err := NewRequestBuilder[Empty, item](api).Get("/batch").DoMultipart(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 6 {
+		t.Fatalf("got %d parts, want 6", len(parts))
+	}
+
+	tests := []struct {
+		name       string
+		part       Part[item]
+		wantStatus int
+		wantID     int
+		wantCID    string
+		wantErr    bool
+	}{
+		{"JSON part", parts[0], http.StatusOK, 1, "<item-1>", false},
+		{"embedded response", parts[1], http.StatusCreated, 2, "<item-2>", false},
+		{"embedded response without body", parts[2], http.StatusNotFound, 0, "<item-3>", false},
+		{"file part", parts[3], http.StatusOK, 4, "", false},
+		{"invalid JSON", parts[4], http.StatusOK, 0, "", true},
+		{"invalid embedded response", parts[5], http.StatusOK, 0, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.part
+			if (p.Err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", p.Err, tt.wantErr)
+			}
+			if p.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", p.StatusCode, tt.wantStatus)
+			}
+			if p.ContentID != tt.wantCID {
+				t.Errorf("got Content-ID %q, want %q", p.ContentID, tt.wantCID)
+			}
+			if tt.wantErr {
+				return
+			}
+			if p.Decoded == nil || p.Decoded.ID != tt.wantID {
+				t.Errorf("got decoded %+v, want id %d", p.Decoded, tt.wantID)
+			}
+		})
+	}
+
+	// Headers of embedded responses replace headers of the part
+	if got := parts[1].Header.Get("X-Sub"); got != "2" {
+		t.Errorf("got X-Sub %q of embedded response, want %q", got, "2")
+	}
+	// Headers of other parts are kept, e.g. to get file name or content type
+	_, params, err := mime.ParseMediaType(parts[3].Header.Get("Content-Disposition"))
+	if err != nil || params["filename"] != "item-4.json" || params["name"] != "item" {
+		t.Errorf("got Content-Disposition %q, want file name item-4.json", parts[3].Header.Get("Content-Disposition"))
+	}
+	if got := parts[3].Header.Get("Content-Type"); got != "application/json; charset=utf-8" {
+		t.Errorf("got Content-Type %q, want %q", got, "application/json; charset=utf-8")
+	}
+}
+
+func TestDoMultipartNotMultipart(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+	}{
+		{"JSON", "application/json"},
+		{"missing boundary", "multipart/mixed"},
+		{"invalid media type", "multipart/mixed; boundary="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				io.WriteString(w, `{"id":1}`)
+			}))
+			defer srv.Close()
+
+			api := NewAPI(WithBaseURL(srv.URL))
+			_, err := NewRequestBuilder[Empty, Empty](api).Get("/batch").DoMultipart(context.Background())
+			if !errors.Is(err, ErrNotMultipart) {
+				t.Errorf("got error %v, want %v", err, ErrNotMultipart)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"net/http"
+	"time"
+)
+
+// Observer receives events of request execution, e.g. to record metrics (see otelmetrics subpackage).
+// Methods are called concurrently and must not modify request or response.
+type Observer interface {
+	// RequestStarted is called before request is sent (before waiting for rate limiter).
+	RequestStarted(req *http.Request)
+	// RequestFinished is called once response is received or request failed. Duration includes all retry
+	// attempts and waits between them, attempts is a number of performed attempts.
+	RequestFinished(req *http.Request, resp *http.Response, err error, duration time.Duration, attempts int)
+}
+
+// WithObserver adds observer which receives events of every request.
+func WithObserver(o Observer) Option {
+	return func(opts *Options) {
+		opts.Observers = append(opts.Observers, o)
+	}
+}
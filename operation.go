@@ -0,0 +1,100 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Operation describes a single API operation once (e.g. generated from OpenAPI specification),
+// so it can be executed with different parameters.
+type Operation[Req any] struct {
+	Method string
+	// PathTemplate is a resource path with parameters in braces, e.g. "/users/{id}/posts".
+	PathTemplate string
+	// PathParams are substituted into PathTemplate, values are escaped.
+	PathParams map[string]string
+	// QueryParams are added to query parameters of the request.
+	QueryParams url.Values
+	Headers     http.Header
+	// Body is encoded with EncoderDecoder, unless Req implements BodyMarshaler.
+	Body *Req
+}
+
+// Path returns PathTemplate with substituted path params. Fails if any parameter of the template
+// doesn't have a value or template is malformed.
+func (op Operation[Req]) Path() (string, error) {
+	var b strings.Builder
+	rest := op.PathTemplate
+	for {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			b.WriteString(rest)
+			return b.String(), nil
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("malformed path template %q", op.PathTemplate)
+		}
+		name := rest[start+1 : start+end]
+		value, ok := op.PathParams[name]
+		if !ok {
+			return "", fmt.Errorf("missing value of path param %q", name)
+		}
+		b.WriteString(rest[:start])
+		b.WriteString(url.PathEscape(value))
+		rest = rest[start+end+1:]
+	}
+}
+
+// NewOperationRequestBuilder creates request builder of the operation, so it can be customized with hooks
+// before execution.
+func NewOperationRequestBuilder[Req any, Resp any](api *API, op Operation[Req]) (*RequestBuilder[Req, Resp], error) {
+	path, err := op.Path()
+	if err != nil {
+		return nil, err
+	}
+	method := op.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	rb := NewRequestBuilder[Req, Resp](api)
+	rb.method = strings.ToUpper(method)
+	rb.resourcePath = path
+	rb.body = op.Body
+	if len(op.QueryParams) != 0 {
+		rb.requestOptions = append(rb.requestOptions, withRequestQueryValues(op.QueryParams))
+	}
+	if len(op.Headers) != 0 {
+		rb.requestOptions = append(rb.requestOptions, WithRequestHeaders(op.Headers))
+	}
+	return rb, nil
+}
+
+// ExecuteOperation executes the operation and decodes response into Resp object (see DoWithDecode).
+func ExecuteOperation[Req any, Resp any](ctx context.Context, api *API, op Operation[Req], enc ...EncoderDecoder) (*Resp, error) {
+	rb, err := NewOperationRequestBuilder[Req, Resp](api, op)
+	if err != nil {
+		return nil, err
+	}
+	return rb.DoWithDecode(ctx, enc...)
+}
+
+func withRequestQueryValues(values url.Values) RequestOption {
+	return func(req *http.Request) error {
+		q := req.URL.Query()
+		for key, vals := range values {
+			for _, v := range vals {
+				q.Add(key, v)
+			}
+		}
+		req.URL.RawQuery = q.Encode()
+		return nil
+	}
+}
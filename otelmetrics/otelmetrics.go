@@ -0,0 +1,162 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+//
+// Package otelmetrics provides clientx.Observer which records OpenTelemetry metrics of HTTP client
+// requests according to semantic conventions. It lives in a separate package to keep
+// go.opentelemetry.io/otel dependency optional.
+//
+//	observer, err := otelmetrics.New(meterProvider)
+//	if err != nil {
+//		return err
+//	}
+//	api := clientx.NewAPI(
+//		clientx.WithBaseURL("https://php-noise.com"),
+//		clientx.WithObserver(observer),
+//	)
+package otelmetrics
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/0x9ef/clientx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ScopeName is an instrumentation scope name of the meter.
+const ScopeName = "github.com/0x9ef/clientx/otelmetrics"
+
+// Names of recorded instruments.
+const (
+	// RequestDuration is a histogram of request durations in seconds, including retries.
+	RequestDuration = "http.client.request.duration"
+	// ActiveRequests is an up-down counter of requests in flight.
+	ActiveRequests = "http.client.active_requests"
+	// RequestRetries is a counter of retry attempts (attempts except the first one).
+	RequestRetries = "clientx.request.retries"
+)
+
+// durationBuckets are explicit bucket boundaries of RequestDuration advised by semantic conventions.
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+
+// Observer records metrics of requests, it's safe for concurrent use.
+type Observer struct {
+	duration metric.Float64Histogram
+	active   metric.Int64UpDownCounter
+	retries  metric.Int64Counter
+}
+
+var _ clientx.Observer = (*Observer)(nil)
+
+// New creates Observer with instruments of meter provider mp, global meter provider is used if mp is nil.
+func New(mp metric.MeterProvider) (*Observer, error) {
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	meter := mp.Meter(ScopeName)
+
+	duration, err1 := meter.Float64Histogram(RequestDuration,
+		metric.WithDescription("Duration of HTTP client requests."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(durationBuckets...),
+	)
+	active, err2 := meter.Int64UpDownCounter(ActiveRequests,
+		metric.WithDescription("Number of active HTTP requests."),
+		metric.WithUnit("{request}"),
+	)
+	retries, err3 := meter.Int64Counter(RequestRetries,
+		metric.WithDescription("Number of retried HTTP client requests attempts."),
+		metric.WithUnit("{retry}"),
+	)
+	if err := errors.Join(err1, err2, err3); err != nil {
+		return nil, err
+	}
+	return &Observer{
+		duration: duration,
+		active:   active,
+		retries:  retries,
+	}, nil
+}
+
+func (o *Observer) RequestStarted(req *http.Request) {
+	o.active.Add(req.Context(), 1, metric.WithAttributes(requestAttrs(req)...))
+}
+
+func (o *Observer) RequestFinished(req *http.Request, resp *http.Response, err error, duration time.Duration, attempts int) {
+	ctx := req.Context()
+	attrs := requestAttrs(req)
+	o.active.Add(ctx, -1, metric.WithAttributes(attrs...))
+
+	if resp != nil {
+		attrs = append(attrs, attribute.Int("http.response.status_code", resp.StatusCode))
+	}
+	if errType := errorType(resp, err); errType != "" {
+		attrs = append(attrs, attribute.String("error.type", errType))
+	}
+	set := metric.WithAttributes(attrs...)
+	o.duration.Record(ctx, duration.Seconds(), set)
+	if attempts > 1 {
+		o.retries.Add(ctx, int64(attempts-1), set)
+	}
+}
+
+// requestAttrs returns attributes of request required by semantic conventions and request tags
+// (see clientx.WithRequestTag).
+func requestAttrs(req *http.Request) []attribute.KeyValue {
+	tags := clientx.RequestTags(req)
+	attrs := make([]attribute.KeyValue, 0, 5+len(tags))
+	attrs = append(attrs, attribute.String("http.request.method", method(req.Method)))
+	host, port := req.URL.Hostname(), req.URL.Port()
+	if host != "" {
+		attrs = append(attrs, attribute.String("server.address", host))
+	}
+	if port == "" {
+		switch req.URL.Scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+	}
+	if p, err := strconv.Atoi(port); err == nil {
+		attrs = append(attrs, attribute.Int("server.port", p))
+	}
+	for k, v := range tags {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+	return attrs
+}
+
+// method returns known HTTP method or _OTHER as required by semantic conventions.
+func method(m string) string {
+	switch m {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return m
+	case "":
+		return http.MethodGet
+	default:
+		return "_OTHER"
+	}
+}
+
+// errorType describes class of error: status code for 4xx/5xx responses, otherwise type of err.
+func errorType(resp *http.Response, err error) string {
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return "timeout"
+		}
+		return "_OTHER"
+	}
+	if resp != nil && resp.StatusCode >= 400 {
+		return strconv.Itoa(resp.StatusCode)
+	}
+	return ""
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Preflight contains allowed methods and CORS policy discovered by OPTIONS request.
+type Preflight struct {
+	// StatusCode is a status code of OPTIONS response.
+	StatusCode int
+	// Allow is a list of methods from Allow header.
+	Allow []string
+	// AllowOrigin is a value of Access-Control-Allow-Origin header.
+	AllowOrigin string
+	// AllowMethods is a list of methods from Access-Control-Allow-Methods header.
+	AllowMethods []string
+	// AllowHeaders is a list of headers from Access-Control-Allow-Headers header.
+	AllowHeaders []string
+	// ExposeHeaders is a list of headers from Access-Control-Expose-Headers header.
+	ExposeHeaders []string
+	// AllowCredentials is a value of Access-Control-Allow-Credentials header.
+	AllowCredentials bool
+	// MaxAge is a value of Access-Control-Max-Age header.
+	MaxAge time.Duration
+}
+
+// IsAllowed reports whether method is listed in Allow or Access-Control-Allow-Methods headers.
+func (p *Preflight) IsAllowed(method string) bool {
+	for _, methods := range [][]string{p.Allow, p.AllowMethods} {
+		for _, m := range methods {
+			if m == "*" || strings.EqualFold(m, method) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Preflight issues OPTIONS request to path and parses Allow and CORS headers from the response.
+// To get CORS headers, you may need to pass Origin and Access-Control-Request-Method headers via request options.
+func (api *API) Preflight(ctx context.Context, path string, opts ...RequestOption) (*Preflight, error) {
+	resp, err := NewRequestBuilder[Empty, Empty](api).
+		Options(path, opts...).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return parsePreflight(resp), nil
+}
+
+func parsePreflight(resp *http.Response) *Preflight {
+	p := &Preflight{
+		StatusCode:    resp.StatusCode,
+		Allow:         splitHeaderList(resp.Header.Values("Allow")),
+		AllowOrigin:   resp.Header.Get("Access-Control-Allow-Origin"),
+		AllowMethods:  splitHeaderList(resp.Header.Values("Access-Control-Allow-Methods")),
+		AllowHeaders:  splitHeaderList(resp.Header.Values("Access-Control-Allow-Headers")),
+		ExposeHeaders: splitHeaderList(resp.Header.Values("Access-Control-Expose-Headers")),
+	}
+	p.AllowCredentials, _ = strconv.ParseBool(resp.Header.Get("Access-Control-Allow-Credentials"))
+	if secs, err := strconv.Atoi(resp.Header.Get("Access-Control-Max-Age")); err == nil {
+		p.MaxAge = time.Duration(secs) * time.Second
+	}
+	return p
+}
+
+// splitHeaderList splits comma-separated header values, e.g. "GET, POST" into ["GET", "POST"].
+func splitHeaderList(values []string) []string {
+	var list []string
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
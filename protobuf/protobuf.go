@@ -0,0 +1,60 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+//
+// Package protobuf provides clientx.EncoderDecoder for application/x-protobuf endpoints.
+// It lives in a separate package to keep google.golang.org/protobuf dependency optional.
+package protobuf
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/0x9ef/clientx"
+	"google.golang.org/protobuf/proto"
+)
+
+// ContentType is a media type of protobuf encoded payloads.
+const ContentType = "application/x-protobuf"
+
+// EncoderDecoder encodes request bodies and decodes responses in protobuf binary format.
+// Both Req and Resp types of RequestBuilder must be generated protobuf messages,
+// i.e. *Req and *Resp must implement proto.Message, otherwise encoding/decoding fails.
+//
+//	clientx.NewRequestBuilder[pb.CreateOfferRequest, pb.Offer](api).
+//		Post("/offers", &req).
+//		DoWithDecode(ctx, protobuf.EncoderDecoder)
+var EncoderDecoder clientx.EncoderDecoder = encoderDecoder{}
+
+type encoderDecoder struct{}
+
+var _ clientx.ContentTyper = encoderDecoder{}
+
+func (encoderDecoder) ContentType() string {
+	return ContentType
+}
+
+func (encoderDecoder) Encode(w io.Writer, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf: %T doesn't implement proto.Message", v)
+	}
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func (encoderDecoder) Decode(r io.Reader, dst any) error {
+	m, ok := dst.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf: %T doesn't implement proto.Message", dst)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, m)
+}
@@ -6,7 +6,10 @@ package clientx
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/time/rate"
@@ -14,6 +17,14 @@ import (
 
 var ErrRateLimitExceeded = errors.New("rate limit is exceeded")
 
+// ErrRateLimitDeadline is returned by Wait when rate limiter can't allow request before context deadline.
+// It wraps ErrRateLimitExceeded.
+var ErrRateLimitDeadline = fmt.Errorf("%w: wait would exceed context deadline", ErrRateLimitExceeded)
+
+// ErrLimiterUnsupported is returned when configured Limiter doesn't implement optional interface
+// required by operation, e.g. AllowLimiter for fail-fast rate limiting.
+var ErrLimiterUnsupported = errors.New("operation isn't supported by limiter")
+
 // Limiter is a general interface responsible for rate-limiting functional.
 type Limiter interface {
 	Wait(ctx context.Context) error
@@ -21,6 +32,27 @@ type Limiter interface {
 	SetLimitAt(at time.Time, limit rate.Limit)
 }
 
+// AllowLimiter is an optional interface of Limiter required by fail-fast rate limiting (see WithFailFastRateLimit).
+type AllowLimiter interface {
+	// Allow reports whether request may be performed now, without waiting. Consumes a token if it's allowed.
+	Allow() bool
+}
+
+// AvailableLimiter is an optional interface of Limiter required by API.WaitUntilAllowed.
+type AvailableLimiter interface {
+	// WaitAvailable blocks until n tokens are available, without consuming them.
+	WaitAvailable(ctx context.Context, n int) error
+}
+
+// LimitReporter is an optional interface of Limiter required by API.RateLimit and adaptation
+// to server-reported rate limits (see WithRateLimitParseFn).
+type LimitReporter interface {
+	// Limit returns current (effective) limit.
+	Limit() rate.Limit
+	// Burst returns current (effective) burst.
+	Burst() int
+}
+
 // This bucket implementation is wrapper around rate.Limiter.
 //
 // Using adaptive rate-limiting may cause Thundering herd problem, when all clients (in our situation - goroutines)
@@ -33,7 +65,12 @@ type adaptiveBucketLimiter struct {
 	nextResetEvents []func()
 }
 
-var _ Limiter = (*adaptiveBucketLimiter)(nil)
+var (
+	_ Limiter          = (*adaptiveBucketLimiter)(nil)
+	_ AllowLimiter     = (*adaptiveBucketLimiter)(nil)
+	_ AvailableLimiter = (*adaptiveBucketLimiter)(nil)
+	_ LimitReporter    = (*adaptiveBucketLimiter)(nil)
+)
 
 func newAdaptiveBucketLimiter(limit rate.Limit, burst int) *adaptiveBucketLimiter {
 	return &adaptiveBucketLimiter{
@@ -47,7 +84,41 @@ func newUnlimitedAdaptiveBucketLimiter() *adaptiveBucketLimiter {
 }
 
 func (l *adaptiveBucketLimiter) Wait(ctx context.Context) error {
+	l.applyResetEvents()
+	return waitReservation(ctx, l.r)
+}
+
+func (l *adaptiveBucketLimiter) WaitAvailable(ctx context.Context, n int) error {
+	l.applyResetEvents()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	now := time.Now()
+	res := l.r.ReserveN(now, n)
+	if !res.OK() {
+		return fmt.Errorf("%w: %d tokens exceed burst %d", ErrRateLimitExceeded, n, l.r.Burst())
+	}
+	delay := res.DelayFrom(now)
+	res.CancelAt(now) // reservation is used only to find out when tokens are available
+	if delay == 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && now.Add(delay).After(deadline) {
+		return ErrRateLimitDeadline
+	}
+	_, err := sleepContext(ctx, delay)
+	return err
+}
+
+func (l *adaptiveBucketLimiter) Allow() bool {
+	l.applyResetEvents()
+	return l.r.Allow()
+}
+
+// applyResetEvents executes scheduled events if reset time has come.
+func (l *adaptiveBucketLimiter) applyResetEvents() {
 	l.mu.Lock()
+	defer l.mu.Unlock()
 	if l.tryReset() {
 		for i := range l.nextResetEvents {
 			l.nextResetEvents[i]()
@@ -55,9 +126,37 @@ func (l *adaptiveBucketLimiter) Wait(ctx context.Context) error {
 		l.nextResetAt = time.Time{}               // reset time
 		l.nextResetEvents = l.nextResetEvents[:0] // reset consumed events
 	}
-	l.mu.Unlock()
+}
 
-	return l.r.Wait(ctx)
+// waitReservation reserves a token and waits for it. If the token isn't available before context deadline,
+// the reservation is cancelled immediately, so the token isn't held by request that would fail anyway.
+func waitReservation(ctx context.Context, r *rate.Limiter) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	now := time.Now()
+	res := r.ReserveN(now, 1)
+	if !res.OK() {
+		return fmt.Errorf("%w: burst %d is exceeded", ErrRateLimitExceeded, r.Burst())
+	}
+	delay := res.DelayFrom(now)
+	if delay == 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && now.Add(delay).After(deadline) {
+		res.CancelAt(now)
+		return ErrRateLimitDeadline
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		res.Cancel()
+		return ctx.Err()
+	}
 }
 
 func (l *adaptiveBucketLimiter) SetBurstAt(at time.Time, burst int) {
@@ -72,16 +171,194 @@ func (l *adaptiveBucketLimiter) SetLimitAt(at time.Time, limit rate.Limit) {
 	})
 }
 
+func (l *adaptiveBucketLimiter) Limit() rate.Limit {
+	l.applyResetEvents()
+	return l.r.Limit()
+}
+
+func (l *adaptiveBucketLimiter) Burst() int {
+	l.applyResetEvents()
+	return l.r.Burst()
+}
+
+// insertEvent schedules f to be executed at reset time, or executes it immediately if at isn't in the future.
 func (l *adaptiveBucketLimiter) insertEvent(at time.Time, f func()) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	if !at.After(time.Now()) {
+		f()
+		return
+	}
 	l.nextResetAt = at
 	l.nextResetEvents = append(l.nextResetEvents, f)
 }
 
+// tryReset reports whether reset time has come.
 func (l *adaptiveBucketLimiter) tryReset() bool {
+	return !l.nextResetAt.IsZero() && !time.Now().Before(l.nextResetAt)
+}
+
+// newRateLimiter returns limiter with configured limits or unlimited one if rl is nil.
+func newRateLimiter(rl *OptionRateLimit) *adaptiveBucketLimiter {
+	if rl == nil {
+		return newUnlimitedAdaptiveBucketLimiter()
+	}
+	limit := rate.Every(rl.Per / time.Duration(rl.Limit))
+	return newAdaptiveBucketLimiter(limit, rl.Burst)
+}
+
+// keyedLimiter holds independent limiters (buckets) created lazily per key.
+type keyedLimiter struct {
+	mu         sync.Mutex
+	limiters   map[string]Limiter
+	newLimiter func(key string) Limiter
+}
+
+func newKeyedLimiter(newLimiter func(key string) Limiter) *keyedLimiter {
+	return &keyedLimiter{
+		limiters:   make(map[string]Limiter),
+		newLimiter: newLimiter,
+	}
+}
+
+func (l *keyedLimiter) get(key string) Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limiter, ok := l.limiters[key]
+	if !ok {
+		limiter = l.newLimiter(key)
+		l.limiters[key] = limiter
+	}
+	return limiter
+}
+
+// limiterFor returns rate limiter (bucket) of request.
+func (api *API) limiterFor(req *http.Request) Limiter {
+	if api.keyedLimiters == nil {
+		return api.limiter
+	}
+	key := api.options.RateLimitKeyFunc(req)
+	if key == "" {
+		return api.limiter
+	}
+	return api.keyedLimiters.get(key)
+}
+
+type failFastKey struct{}
+
+// isFailFast reports whether request has to fail instead of waiting for rate limiter (see WithFailFastRateLimit).
+func isFailFast(ctx context.Context) bool {
+	return ctx.Value(failFastKey{}) != nil
+}
+
+// rateLimitBase is a limit and burst of limiter before it was adapted to server-reported rate limits.
+type rateLimitBase struct {
+	limit rate.Limit
+	burst int
+}
+
+// adaptRateLimit adjusts rate limiter (bucket) of req according to rate limits reported by server
+// (see RateLimitParseFn): remaining requests are spread evenly until resetAt, then the original
+// limit and burst of the limiter are restored.
+func (api *API) adaptRateLimit(req *http.Request, resp *http.Response, body []byte) {
+	if api.options.RateLimitParseFn == nil {
+		return
+	}
+	_, remaining, resetAt, err := api.options.RateLimitParseFn(resp, body)
+	if err != nil {
+		api.logger().Warn("failed to parse rate limits of response", "error", err)
+		return
+	}
 	now := time.Now()
-	return l.nextResetAt.Equal(now) || l.nextResetAt.After(now)
+	window := resetAt.Sub(now)
+	if window <= 0 || remaining < 0 {
+		return // nothing to adapt to
+	}
+
+	limiter := api.limiterFor(req)
+	reporter, ok := limitReporter(limiter)
+	if !ok {
+		api.logger().Debug("rate limiter isn't adapted, it doesn't implement LimitReporter", "limiter", fmt.Sprintf("%T", limiter))
+		return
+	}
+	v, _ := api.rateLimitBases.LoadOrStore(limiter, rateLimitBase{limit: reporter.Limit(), burst: reporter.Burst()})
+	base := v.(rateLimitBase)
+
+	limit := rate.Every(window) // next token isn't available before reset
+	burst := 1
+	if remaining > 0 {
+		limit = rate.Limit(float64(remaining) / window.Seconds())
+		burst = min(remaining, max(base.burst, 1))
+	}
+	if limit > base.limit {
+		limit = base.limit // server allows more than configured
+	}
+	limiter.SetLimitAt(now, limit)
+	limiter.SetBurstAt(now, burst)
+	limiter.SetLimitAt(resetAt, base.limit)
+	limiter.SetBurstAt(resetAt, base.burst)
+}
+
+// queueLimiter limits number of goroutines waiting for the underlying limiter.
+type queueLimiter struct {
+	Limiter
+	waiters  int64
+	maxDepth int64
+}
+
+var (
+	_ Limiter          = (*queueLimiter)(nil)
+	_ AvailableLimiter = (*queueLimiter)(nil)
+)
+
+func newQueueLimiter(l Limiter, maxDepth int) *queueLimiter {
+	return &queueLimiter{
+		Limiter:  l,
+		maxDepth: int64(maxDepth),
+	}
+}
+
+func (l *queueLimiter) Wait(ctx context.Context) error {
+	defer atomic.AddInt64(&l.waiters, -1)
+	if atomic.AddInt64(&l.waiters, 1) > l.maxDepth {
+		return ErrRateLimitExceeded
+	}
+	return l.Limiter.Wait(ctx)
+}
+
+func (l *queueLimiter) WaitAvailable(ctx context.Context, n int) error {
+	a, ok := l.Limiter.(AvailableLimiter)
+	if !ok {
+		return unsupportedAvailableLimiter(l.Limiter)
+	}
+	defer atomic.AddInt64(&l.waiters, -1)
+	if atomic.AddInt64(&l.waiters, 1) > l.maxDepth {
+		return ErrRateLimitExceeded
+	}
+	return a.WaitAvailable(ctx, n)
+}
+
+// allowLimiter returns AllowLimiter of l, queueLimiter is skipped since it doesn't limit non-waiting requests.
+func allowLimiter(l Limiter) (AllowLimiter, bool) {
+	a, ok := unwrapQueueLimiter(l).(AllowLimiter)
+	return a, ok
+}
+
+// limitReporter returns LimitReporter of l, queueLimiter is skipped since it doesn't alter limits.
+func limitReporter(l Limiter) (LimitReporter, bool) {
+	r, ok := unwrapQueueLimiter(l).(LimitReporter)
+	return r, ok
+}
+
+func unwrapQueueLimiter(l Limiter) Limiter {
+	if q, ok := l.(*queueLimiter); ok {
+		return q.Limiter
+	}
+	return l
+}
+
+func unsupportedAvailableLimiter(l Limiter) error {
+	return fmt.Errorf("%w: %T doesn't implement AvailableLimiter", ErrLimiterUnsupported, l)
 }
 
 func validateResetAt(at time.Time) time.Time {
@@ -0,0 +1,216 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestAdaptRateLimit(t *testing.T) {
+	const budget = 10
+	var calls int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remaining := budget - atomic.AddInt64(&calls, 1)
+		w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+	}))
+	defer srv.Close()
+
+	api := NewAPI(
+		WithBaseURL(srv.URL),
+		WithRateLimit(100, budget, time.Second),
+		WithRateLimitParseFn(func(resp *http.Response, _ []byte) (int, int, time.Time, error) {
+			remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+			if err != nil {
+				return 0, 0, time.Time{}, err
+			}
+			reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+			if err != nil {
+				return 0, 0, time.Time{}, err
+			}
+			return budget, remaining, time.Unix(reset, 0), nil
+		}),
+	)
+
+	prevLimit, _, _ := api.RateLimit()
+	for i := 0; i < 5; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, err := NewRequestBuilder[Empty, Empty](api).Get("/").Do(ctx)
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		limit, burst, _ := api.RateLimit()
+		if limit >= prevLimit {
+			t.Errorf("request %d: limit %v isn't tightened, previous %v", i, limit, prevLimit)
+		}
+		if want := budget - (i + 1); burst != want {
+			t.Errorf("request %d: got burst %d, want %d", i, burst, want)
+		}
+		prevLimit = limit
+	}
+}
+
+func TestFailFastRateLimit(t *testing.T) {
+	var calls int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&calls, 1)
+	}))
+	defer srv.Close()
+
+	api := NewAPI(WithBaseURL(srv.URL), WithRateLimit(1, 1, time.Hour))
+	tests := []struct {
+		name    string
+		wantErr error
+	}{
+		{"allowed", nil},
+		{"rejected", ErrRateLimitExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			_, err := NewRequestBuilder[Empty, Empty](api).Get("/", WithFailFastRateLimit()).Do(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("request returned after %v, want no waiting", elapsed)
+			}
+		})
+	}
+	if n := atomic.LoadInt64(&calls); n != 1 {
+		t.Errorf("server got %d requests, want 1", n)
+	}
+}
+
+func TestPerHostRateLimit(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	first, second := httptest.NewServer(handler), httptest.NewServer(handler)
+	defer first.Close()
+	defer second.Close()
+
+	api := NewAPI(
+		WithBaseURL(first.URL),
+		WithNamedBaseURL("second", second.URL),
+		WithPerHostRateLimit(1, 1, time.Hour),
+	)
+	do := func(endpoint string) error {
+		rb := NewRequestBuilder[Empty, Empty](api).Get("/", WithFailFastRateLimit())
+		if endpoint != "" {
+			rb = rb.WithEndpoint(endpoint)
+		}
+		_, err := rb.Do(context.Background())
+		return err
+	}
+
+	// Each host has its own bucket with a single token
+	if err := do(""); err != nil {
+		t.Fatalf("first host: %v", err)
+	}
+	if err := do("second"); err != nil {
+		t.Fatalf("second host isn't limited by bucket of the first one: %v", err)
+	}
+	if err := do(""); !errors.Is(err, ErrRateLimitExceeded) {
+		t.Errorf("first host: got error %v, want %v", err, ErrRateLimitExceeded)
+	}
+	if err := do("second"); !errors.Is(err, ErrRateLimitExceeded) {
+		t.Errorf("second host: got error %v, want %v", err, ErrRateLimitExceeded)
+	}
+}
+
+// waitOnlyLimiter exposes only methods of Limiter interface, e.g. like user-supplied limiter does.
+type waitOnlyLimiter struct {
+	Limiter
+}
+
+func TestFailFastRateLimitUnsupported(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	api := NewAPI(WithBaseURL(srv.URL), WithLimiter(waitOnlyLimiter{newRateLimiter(nil)}))
+	_, err := NewRequestBuilder[Empty, Empty](api).Get("/", WithFailFastRateLimit()).Do(context.Background())
+	if !errors.Is(err, ErrLimiterUnsupported) {
+		t.Errorf("got error %v, want %v", err, ErrLimiterUnsupported)
+	}
+
+	// Requests without fail-fast just wait
+	if _, err := NewRequestBuilder[Empty, Empty](api).Get("/").Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWaitUntilAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		n       int
+		wantErr error
+	}{
+		{"available", []Option{WithRateLimit(10, 10, time.Second)}, 5, nil},
+		{"exceeds burst", []Option{WithRateLimit(10, 10, time.Second)}, 11, ErrRateLimitExceeded},
+		{"queue limiter", []Option{WithRateLimit(10, 10, time.Second), WithMaxQueueDepth(1)}, 5, nil},
+		{"unsupported limiter", []Option{WithLimiter(waitOnlyLimiter{newRateLimiter(nil)})}, 1, ErrLimiterUnsupported},
+		{"unsupported limiter in queue", []Option{WithLimiter(waitOnlyLimiter{newRateLimiter(nil)}), WithMaxQueueDepth(1)}, 1, ErrLimiterUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			err := NewAPI(tt.opts...).WaitUntilAllowed(ctx, tt.n)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []Option
+		wantLimit rate.Limit
+		wantBurst int
+		wantOK    bool
+	}{
+		{"unlimited", nil, rate.Inf, 1, true},
+		{"configured", []Option{WithRateLimit(10, 5, time.Second)}, 10, 5, true},
+		{"queue limiter", []Option{WithRateLimit(10, 5, time.Second), WithMaxQueueDepth(1)}, 10, 5, true},
+		{"unsupported limiter", []Option{WithLimiter(waitOnlyLimiter{newRateLimiter(nil)})}, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit, burst, ok := NewAPI(tt.opts...).RateLimit()
+			if limit != tt.wantLimit || burst != tt.wantBurst || ok != tt.wantOK {
+				t.Errorf("got (%v, %d, %v), want (%v, %d, %v)", limit, burst, ok, tt.wantLimit, tt.wantBurst, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestAdaptRateLimitUnsupported(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	api := NewAPI(
+		WithBaseURL(srv.URL),
+		WithLimiter(waitOnlyLimiter{newRateLimiter(nil)}),
+		WithRateLimitParseFn(func(*http.Response, []byte) (int, int, time.Time, error) {
+			return 10, 1, time.Now().Add(time.Minute), nil
+		}),
+	)
+	// Limiter without LimitReporter isn't adapted, request is performed as usual
+	if _, err := NewRequestBuilder[Empty, Empty](api).Get("/").Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -7,15 +7,21 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"sync/atomic"
 )
 
+// ParamEncoder is the interface implemented by types that encode themselves into URL query parameters.
+// Type parameter T is a type of the implementation, it allows to pass params of Req type to RequestBuilder.
 type ParamEncoder[T any] interface {
 	Encode(v url.Values) error
 }
 
+// NormalizeParams converts slice of params into slice of ParamEncoder, so it can be passed to
+// WithEncodableQueryParams as params... (see also WithEncodableQueryParamsSlice).
 func NormalizeParams[T ParamEncoder[T]](params []T) []ParamEncoder[T] {
 	p := make([]ParamEncoder[T], len(params))
 	for i, param := range params {
@@ -27,18 +33,64 @@ func NormalizeParams[T ParamEncoder[T]](params []T) []ParamEncoder[T] {
 type RequestBuilder[Req any, Resp any] struct {
 	client         *client[Req, Resp]
 	method         string
+	endpoint       string
 	resourcePath   string
 	requestOptions []RequestOption
 	body           *Req
+	jsonBody       any
 	errDecodeFn    func(*http.Response) (bool, error)
+	afterDecode    []func(resp *http.Response, decoded *Resp) error
+	afterBuild     []func(req *http.Request) error
+	bodyLimit      int64
+	// retryConditions are merged with (or replace, if replaceRetryConditions is set) API-level retry conditions.
+	retryConditions        []RetryCond
+	replaceRetryConditions bool
+	// dataField is a name of JSON envelope field decoded into Resp, envelope receives the whole envelope.
+	dataField string
+	envelope  any
+	// retryStats receives stats of retry sequence.
+	retryStats *RetryStats
+	// contentTypes are expected media types of response.
+	contentTypes []string
+	onFinish     []func(resp *http.Response, err error, attempts int)
+	// lastRequest is the last built request, see Replay.
+	lastRequest atomic.Pointer[http.Request]
+	// httpClient overrides HTTP client of the API for this request.
+	httpClient *http.Client
 }
 
-func (rb *RequestBuilder[Req, Resp]) encodeRequestPayload(enc EncoderDecoder) (io.ReadCloser, error) {
+// BodyMarshaler is the interface implemented by request types that can marshal themselves into a request body.
+// If Req (or *Req) implements BodyMarshaler, it's used instead of EncoderDecoder to encode the body.
+type BodyMarshaler interface {
+	// MarshalBody returns encoded body and its content type. Empty content type leaves Content-Type header untouched.
+	MarshalBody() (body []byte, contentType string, err error)
+}
+
+// encodeRequestPayload encodes request body. Returns encoded body and content type (if known).
+func (rb *RequestBuilder[Req, Resp]) encodeRequestPayload(enc EncoderDecoder) ([]byte, string, error) {
+	if rb.jsonBody != nil {
+		jsonEnc := JSONEncoderDecoder
+		if j, ok := enc.(*jsonEncoderDecoder); ok {
+			jsonEnc = j // keep options of JSON encoder, e.g. JSONOmitTrailingNewline
+		}
+		payload := &bytes.Buffer{}
+		if err := jsonEnc.Encode(payload, rb.jsonBody); err != nil {
+			return nil, "", err
+		}
+		return payload.Bytes(), "application/json", nil
+	}
+	if m, ok := any(rb.body).(BodyMarshaler); ok {
+		return m.MarshalBody()
+	}
+	if m, ok := any(*rb.body).(BodyMarshaler); ok {
+		return m.MarshalBody()
+	}
+
 	payload := &bytes.Buffer{}
 	if err := enc.Encode(payload, rb.body); err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	return io.NopCloser(payload), nil
+	return payload.Bytes(), "", nil
 }
 
 // NewRequestBuilder creates a new request builder from API for designated Req, Resp.
@@ -53,7 +105,72 @@ func NewRequestBuilder[Req any, Resp any](api *API) *RequestBuilder[Req, Resp] {
 // AfterResponse adds to a chain function that will be executed after response is obtained.
 // Note! The second argument (decoded) in f function is only available when using DoWithDecode method to perform request.
 func (rb *RequestBuilder[Req, Resp]) AfterResponse(f func(resp *http.Response, body []byte) error) *RequestBuilder[Req, Resp] {
-	rb.client.afterResponse = append(rb.client.afterResponse, f)
+	rb.client.afterResponse = append(rb.client.afterResponse, afterResponseHook{f: f})
+	return rb
+}
+
+// AfterDecode adds to a chain function that will be executed after response is decoded by DoWithDecode.
+// The hook may modify *decoded in place (e.g. unwrap an envelope or apply defaults), modifications
+// are reflected in the value returned by DoWithDecode. Returned error aborts the request.
+func (rb *RequestBuilder[Req, Resp]) AfterDecode(f func(resp *http.Response, decoded *Resp) error) *RequestBuilder[Req, Resp] {
+	rb.afterDecode = append(rb.afterDecode, f)
+	return rb
+}
+
+// AfterResponseBestEffort adds to a chain function that will be executed after response is obtained.
+// Unlike AfterResponse, errors returned by f don't abort the request, they are logged by Logger (see WithLogger).
+func (rb *RequestBuilder[Req, Resp]) AfterResponseBestEffort(f func(resp *http.Response, body []byte) error) *RequestBuilder[Req, Resp] {
+	rb.client.afterResponse = append(rb.client.afterResponse, afterResponseHook{f: f, bestEffort: true})
+	return rb
+}
+
+// BeforeRequest adds to a chain function that will be executed before each attempt is sent (including retries).
+// The body argument contains exact encoded body bytes, so there is no need to read req.Body.
+// If the hook replaces req.Body, it should also replace req.GetBody to keep retries working.
+func (rb *RequestBuilder[Req, Resp]) BeforeRequest(f func(req *http.Request, body []byte) error) *RequestBuilder[Req, Resp] {
+	rb.client.beforeRequest = append(rb.client.beforeRequest, f)
+	return rb
+}
+
+// AfterBuild adds to a chain function that will be executed once the request is built (after request options
+// are applied and body is encoded), before the first attempt. Unlike BeforeRequest, it isn't executed again
+// for retries, so test harnesses can assert the final shape of the request. Use req.GetBody to read the body
+// without consuming it. Returned error aborts the request.
+func (rb *RequestBuilder[Req, Resp]) AfterBuild(f func(req *http.Request) error) *RequestBuilder[Req, Resp] {
+	rb.afterBuild = append(rb.afterBuild, f)
+	return rb
+}
+
+// OnFinish adds to a chain function that will be executed once at the end of request execution (Do, DoWithDecode, etc.),
+// regardless of its outcome. resp is nil if no response was obtained (e.g. transport error), attempts is a number
+// of performed attempts (zero if request wasn't sent). It's a reliable place to record metrics of the request.
+// Build doesn't execute request, so hooks aren't called by it.
+func (rb *RequestBuilder[Req, Resp]) OnFinish(f func(resp *http.Response, err error, attempts int)) *RequestBuilder[Req, Resp] {
+	rb.onFinish = append(rb.onFinish, f)
+	return rb
+}
+
+// finish executes OnFinish hooks.
+func (rb *RequestBuilder[Req, Resp]) finish(resp *http.Response, err error, stats RetryStats) {
+	for _, f := range rb.onFinish {
+		f(resp, err, stats.Attempts)
+	}
+}
+
+// CaptureCookies stores cookies set by response (Set-Cookie headers) into dst.
+// Useful with DoWithDecode, which doesn't return *http.Response, e.g. to echo back CSRF token cookie.
+func (rb *RequestBuilder[Req, Resp]) CaptureCookies(dst *[]*http.Cookie) *RequestBuilder[Req, Resp] {
+	return rb.AfterResponse(func(resp *http.Response, _ []byte) error {
+		*dst = resp.Cookies()
+		return nil
+	})
+}
+
+// CaptureRetryStats stores stats of the retry sequence into dst: number of attempts, total wait time
+// and the reason why retrying was stopped, e.g. to distinguish "gave up after N attempts" from
+// "gave up after elapsed budget" in metrics. Stats are stored even if request fails.
+func (rb *RequestBuilder[Req, Resp]) CaptureRetryStats(dst *RetryStats) *RequestBuilder[Req, Resp] {
+	rb.retryStats = dst
 	return rb
 }
 
@@ -64,23 +181,145 @@ func (rb *RequestBuilder[Req, Resp]) WithForm(obj url.Values) *RequestBuilder[Re
 }
 
 // WithStructQueryParams sets URL query parameters from structure by accesing field with provided tag alias.
+// Field values are rendered with encoders set by WithQueryEncoder and WithQueryBoolFormat.
 func (rb *RequestBuilder[Req, Resp]) WithStructQueryParams(tag string, params ...Req) *RequestBuilder[Req, Resp] {
-	rb.requestOptions = append(rb.requestOptions, WithRequestQueryParams(tag, params...))
+	rb.requestOptions = append(rb.requestOptions, withRequestQueryParams(tag, rb.client.api.options.QueryEncoders, params...))
 	return rb
 }
 
 // WithEncodableQueryParams sets URL query parameters from structure which implements ParamEncoder interface.
+// Params are encoded in order into the same values, so the last write of a key wins (see WithRequestQueryEncodableParams).
 func (rb *RequestBuilder[Req, Resp]) WithEncodableQueryParams(params ...ParamEncoder[Req]) *RequestBuilder[Req, Resp] {
 	rb.requestOptions = append(rb.requestOptions, WithRequestQueryEncodableParams(params...))
 	return rb
 }
 
+// WithEncodableQueryParamsSlice is like WithEncodableQueryParams, but accepts slice of Req. Req (or *Req) must
+// implement ParamEncoder interface, otherwise building of the request fails.
+func (rb *RequestBuilder[Req, Resp]) WithEncodableQueryParamsSlice(params []Req) *RequestBuilder[Req, Resp] {
+	encoders := make([]ParamEncoder[Req], 0, len(params))
+	for i := range params {
+		p, ok := any(&params[i]).(ParamEncoder[Req])
+		if !ok {
+			err := fmt.Errorf("%T doesn't implement ParamEncoder", params[i])
+			rb.requestOptions = append(rb.requestOptions, func(*http.Request) error { return err })
+			return rb
+		}
+		encoders = append(encoders, p)
+	}
+	return rb.WithEncodableQueryParams(encoders...)
+}
+
+// WithEncodableQueryParamsAdd is like WithEncodableQueryParams, but values of keys repeated across params accumulate
+// instead of being overwritten (see WithRequestQueryEncodableParamsAdd).
+func (rb *RequestBuilder[Req, Resp]) WithEncodableQueryParamsAdd(params ...ParamEncoder[Req]) *RequestBuilder[Req, Resp] {
+	rb.requestOptions = append(rb.requestOptions, WithRequestQueryEncodableParamsAdd(params...))
+	return rb
+}
+
+// WithRetryConditions adds retry conditions on top of API-level ones (see WithRetry) for this request only,
+// so "retry on 429 globally plus 503 for this call" is expressed as:
+//
+//	NewRequestBuilder[Req, Resp](api).
+//		Get("/items").
+//		WithRetryConditions(func(resp *http.Response, err error) bool {
+//			return resp != nil && resp.StatusCode == http.StatusServiceUnavailable
+//		})
+//
+// Has effect only if retrying mechanism is enabled with WithRetry.
+func (rb *RequestBuilder[Req, Resp]) WithRetryConditions(conditions ...RetryCond) *RequestBuilder[Req, Resp] {
+	rb.retryConditions = append(rb.retryConditions, conditions...)
+	return rb
+}
+
+// WithOnlyRetryConditions replaces API-level retry conditions with the given ones for this request only.
+// Subsequent WithRetryConditions calls append to them. Calling it without conditions disables retries of the request.
+// Has effect only if retrying mechanism is enabled with WithRetry.
+func (rb *RequestBuilder[Req, Resp]) WithOnlyRetryConditions(conditions ...RetryCond) *RequestBuilder[Req, Resp] {
+	rb.retryConditions = append([]RetryCond(nil), conditions...)
+	rb.replaceRetryConditions = true
+	return rb
+}
+
+// retryConditionsFor returns retry conditions of the request composed with API-level ones.
+func (rb *RequestBuilder[Req, Resp]) retryConditionsFor(base []RetryCond) []RetryCond {
+	if rb.replaceRetryConditions {
+		return rb.retryConditions
+	}
+	if len(rb.retryConditions) == 0 {
+		return base
+	}
+	conditions := make([]RetryCond, 0, len(base)+len(rb.retryConditions))
+	conditions = append(conditions, base...)
+	return append(conditions, rb.retryConditions...)
+}
+
 // WithErrorDecode sets custom error decoding function. Will be executed immediately after request is performed.
 func (rb *RequestBuilder[Req, Resp]) WithErrorDecode(f func(resp *http.Response) (bool, error)) *RequestBuilder[Req, Resp] {
 	rb.errDecodeFn = f
 	return rb
 }
 
+// WithEndpoint selects named base URL registered by WithNamedBaseURL for this request.
+func (rb *RequestBuilder[Req, Resp]) WithEndpoint(name string) *RequestBuilder[Req, Resp] {
+	rb.endpoint = name
+	return rb
+}
+
+// WithResponseBodyLimit limits how many bytes of response body are buffered by Do (or read by DoStream), the rest of body is discarded.
+// Useful when only status code and a small body prefix are needed. Hooks receive truncated body.
+// Doesn't apply to DoWithDecode, which always buffers the whole body to decode it.
+func (rb *RequestBuilder[Req, Resp]) WithResponseBodyLimit(n int64) *RequestBuilder[Req, Resp] {
+	rb.bodyLimit = n
+	return rb
+}
+
+// WithExpectedContentType makes request to fail with UnexpectedContentTypeError if media type of response
+// Content-Type doesn't match any of the given ones (parameters like charset are ignored), before response is decoded.
+// It catches misconfigurations like login redirect returning HTML page instead of JSON. Error decode function
+// (see WithErrorDecode) is executed before the check. It applies to all Do* methods, including streaming ones.
+// As for unexpected status, Do returns the response (with buffered body) along with the error.
+func (rb *RequestBuilder[Req, Resp]) WithExpectedContentType(contentTypes ...string) *RequestBuilder[Req, Resp] {
+	rb.contentTypes = append(rb.contentTypes, contentTypes...)
+	return rb
+}
+
+// checkContentType checks media type of response if expected content types are set (see WithExpectedContentType).
+func (rb *RequestBuilder[Req, Resp]) checkContentType(resp *http.Response, body io.Reader) error {
+	if len(rb.contentTypes) == 0 {
+		return nil
+	}
+	return checkContentType(resp, body, rb.contentTypes)
+}
+
+// WithHTTPClient overrides HTTP client of the API for this request only, e.g. to use a longer timeout
+// or a different proxy. The client is used as is, transport options of the API aren't applied to it.
+func (rb *RequestBuilder[Req, Resp]) WithHTTPClient(client *http.Client) *RequestBuilder[Req, Resp] {
+	rb.httpClient = client
+	return rb
+}
+
+// httpClientFor returns HTTP client of the request or client of the API if it isn't overridden.
+func (rb *RequestBuilder[Req, Resp]) httpClientFor(api *API) *http.Client {
+	if rb.httpClient != nil {
+		return rb.httpClient
+	}
+	return api.httpClient
+}
+
+// WithJSONDataField makes DoWithDecode to decode only the named field of JSON envelope into Resp,
+// e.g. "data" of {"data": {...}, "meta": {...}}, so there is no need to define wrapper type per endpoint.
+// If envelope is provided, the whole envelope is also unmarshalled into it to expose other fields (e.g. meta).
+// Fails if response isn't a JSON object or doesn't contain the field.
+func (rb *RequestBuilder[Req, Resp]) WithJSONDataField(field string, envelope ...any) *RequestBuilder[Req, Resp] {
+	rb.dataField = field
+	rb.envelope = nil
+	if len(envelope) != 0 {
+		rb.envelope = envelope[0]
+	}
+	return rb
+}
+
 // Get builds GET request with no body specified.
 // Appends request options (includes request options that were specified at NewRequestBuilder).
 func (rb *RequestBuilder[Req, Resp]) Get(path string, opts ...RequestOption) *RequestBuilder[Req, Resp] {
@@ -99,6 +338,36 @@ func (rb *RequestBuilder[Req, Resp]) Post(path string, body *Req, opts ...Reques
 	return rb
 }
 
+// PostJSON builds POST request with body of any type (e.g. map[string]any) encoded as JSON,
+// so there is no need to declare Req type for ad-hoc requests. Use Empty as Req type. Appends request options.
+// The body is encoded with the request EncoderDecoder if it's a JSON one (see NewJSONEncoderDecoder),
+// otherwise with JSONEncoderDecoder.
+func (rb *RequestBuilder[Req, Resp]) PostJSON(path string, body any, opts ...RequestOption) *RequestBuilder[Req, Resp] {
+	rb.method = http.MethodPost
+	rb.resourcePath = path
+	rb.jsonBody = body
+	rb.requestOptions = append(rb.requestOptions, opts...)
+	return rb
+}
+
+// WithJSONLiteralBody sets literal JSON body of the request (e.g. JSONNull, JSONEmptyArray or JSONEmptyObject),
+// which is sent exactly as is instead of body encoded from Req. Unlike nil body, it's always sent,
+// e.g. Put("/items/1", nil).WithJSONLiteralBody(clientx.JSONNull) sends "null".
+func (rb *RequestBuilder[Req, Resp]) WithJSONLiteralBody(literal string) *RequestBuilder[Req, Resp] {
+	rb.requestOptions = append(rb.requestOptions, WithRequestLiteralBody([]byte(literal), "application/json"))
+	return rb
+}
+
+// PostRaw builds POST request with raw body produced by getBody factory (see WithRequestBodyFactory).
+// The body isn't encoded by EncoderDecoder. Appends request options.
+func (rb *RequestBuilder[Req, Resp]) PostRaw(path string, getBody func() (io.ReadCloser, error), opts ...RequestOption) *RequestBuilder[Req, Resp] {
+	rb.method = http.MethodPost
+	rb.resourcePath = path
+	rb.requestOptions = append(rb.requestOptions, WithRequestBodyFactory(getBody))
+	rb.requestOptions = append(rb.requestOptions, opts...)
+	return rb
+}
+
 // Patch builds PATCH request with specified body (if any). Appends request options.
 func (rb *RequestBuilder[Req, Resp]) Patch(path string, body *Req, opts ...RequestOption) *RequestBuilder[Req, Resp] {
 	rb.method = http.MethodPatch
@@ -159,18 +428,97 @@ func (rb *RequestBuilder[Req, Resp]) Connect(path string, opts ...RequestOption)
 }
 
 // Do executes request and returns *http.Response. Returns error if any.
-func (rb *RequestBuilder[Req, Resp]) Do(ctx context.Context) (*http.Response, error) {
-	resp, _, err := rb.client.do(ctx, rb, false, JSONEncoderDecoder)
+// The request body is encoded with enc (JSON by default).
+// Body of 101 Switching Protocols response isn't read, it's the upgraded connection which implements
+// io.ReadWriteCloser (e.g. for websocket), so the caller owns it and must close it.
+func (rb *RequestBuilder[Req, Resp]) Do(ctx context.Context, enc ...EncoderDecoder) (*http.Response, error) {
+	e, err := rb.client.api.selectEncoderDecoder(enc)
+	if err != nil {
+		rb.finish(nil, err, RetryStats{})
+		return nil, err
+	}
+	resp, _, err := rb.client.do(ctx, rb, false, e)
 	return resp, err
 }
 
 // DoWithDecode executes request and decodes response into Resp object. Returns error if any.
 func (rb *RequestBuilder[Req, Resp]) DoWithDecode(ctx context.Context, enc ...EncoderDecoder) (*Resp, error) {
-	if len(enc) == 0 {
-		enc = append(enc, JSONEncoderDecoder) // JSON by default
-	} else if len(enc) > 1 {
-		return nil, errors.New("enc length should be 0 or 1")
+	e, err := rb.client.api.selectEncoderDecoder(enc)
+	if err != nil {
+		rb.finish(nil, err, RetryStats{})
+		return nil, err
 	}
-	_, decoded, err := rb.client.do(ctx, rb, true, enc[0])
+	_, decoded, err := rb.client.do(ctx, rb, true, e)
 	return decoded, err
 }
+
+// Build builds *http.Request without sending it (dry run). Runs the same pipeline as Do:
+// resolves URL, applies global headers and request options, encodes body with enc (JSON by default).
+// Returned request has replayable body (GetBody), so it can be inspected and sent later.
+func (rb *RequestBuilder[Req, Resp]) Build(ctx context.Context, enc ...EncoderDecoder) (*http.Request, error) {
+	e, err := rb.client.api.selectEncoderDecoder(enc)
+	if err != nil {
+		return nil, err
+	}
+	return rb.client.buildRequest(ctx, rb, e)
+}
+
+// LastRequest returns the last request built by Do, DoWithDecode, etc. or nil if request wasn't built yet.
+// It's useful for debugging, e.g. to dump it with ToCurl. The request must not be modified.
+func (rb *RequestBuilder[Req, Resp]) LastRequest() *http.Request {
+	return rb.lastRequest.Load()
+}
+
+// Replay re-sends the last built request (same URL, headers and body) through the same pipeline as Do:
+// rate limiting, retries and hooks are applied, but request options and body encoding aren't repeated.
+// Useful to reproduce intermittent failures. Fails if request wasn't sent yet or its body can't be re-read.
+func (rb *RequestBuilder[Req, Resp]) Replay(ctx context.Context) (*http.Response, error) {
+	last := rb.lastRequest.Load()
+	if last == nil {
+		return nil, errors.New("no request to replay")
+	}
+	httpReq := last.Clone(ctx)
+	if last.Body != nil && last.Body != http.NoBody {
+		if last.GetBody == nil {
+			return nil, errors.New("request body can't be replayed")
+		}
+		body, err := last.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Body = body
+	}
+
+	c := rb.client
+	ctx, cancel := c.api.operationContext(ctx)
+	defer cancel()
+	release, err := c.api.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	resp, _, stats, err := c.sendRequest(ctx, rb, httpReq, rb.bodyLimit, false)
+	rb.finish(resp, err, stats)
+	return resp, err
+}
+
+// selectEncoderDecoder returns encoder passed per call, or default one of the API (see WithDefaultEncoderDecoder).
+func (api *API) selectEncoderDecoder(enc []EncoderDecoder) (EncoderDecoder, error) {
+	switch len(enc) {
+	case 0:
+		return api.defaultEncoderDecoder(), nil
+	case 1:
+		return enc[0], nil
+	default:
+		return nil, errors.New("enc length should be 0 or 1")
+	}
+}
+
+// defaultEncoderDecoder returns EncoderDecoder set by WithDefaultEncoderDecoder or JSON one.
+func (api *API) defaultEncoderDecoder() EncoderDecoder {
+	if api.options.EncoderDecoder != nil {
+		return api.options.EncoderDecoder
+	}
+	return JSONEncoderDecoder // JSON by default
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestEncodableQueryParamsSlice(t *testing.T) {
+	params := []testParams{
+		{"q": "go", "page": "1"},
+		{"page": "2"},
+	}
+	api := NewAPI(WithBaseURL("https://example.com"))
+	tests := []struct {
+		name string
+		rb   *RequestBuilder[testParams, Empty]
+	}{
+		{"slice", NewRequestBuilder[testParams, Empty](api).Get("/items").WithEncodableQueryParamsSlice(params)},
+		{"normalized", NewRequestBuilder[testParams, Empty](api).Get("/items").WithEncodableQueryParams(NormalizeParams(params)...)},
+	}
+	want := url.Values{"q": {"go"}, "page": {"2"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := tt.rb.Build(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := req.URL.Query(); !reflect.DeepEqual(got, want) {
+				t.Errorf("got query %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestEncodableQueryParamsSliceNotEncoder(t *testing.T) {
+	type notEncoder struct{ Q string }
+	api := NewAPI(WithBaseURL("https://example.com"))
+	_, err := NewRequestBuilder[notEncoder, Empty](api).
+		Get("/items").
+		WithEncodableQueryParamsSlice([]notEncoder{{Q: "go"}}).
+		Build(context.Background())
+	if err == nil {
+		t.Fatal("got no error, want error for type which doesn't implement ParamEncoder")
+	}
+}
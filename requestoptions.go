@@ -4,11 +4,12 @@
 package clientx
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"strings"
+	"reflect"
 
 	"github.com/gorilla/schema"
 )
@@ -17,10 +18,18 @@ type RequestOption func(req *http.Request) error
 
 // WithRequestQueryParams encodes query params automatically by accesing fields with custom tag.
 func WithRequestQueryParams[T any](tag string, params ...T) RequestOption {
+	return withRequestQueryParams(tag, nil, params...)
+}
+
+// withRequestQueryParams encodes query params with custom encoders of field types (see WithQueryEncoder).
+func withRequestQueryParams[T any](tag string, encoders map[reflect.Type]func(reflect.Value) string, params ...T) RequestOption {
 	return func(req *http.Request) error {
 		q := req.URL.Query()
 		enc := schema.NewEncoder()
 		enc.SetAliasTag(tag)
+		for typ, f := range encoders {
+			enc.RegisterEncoder(reflect.Zero(typ).Interface(), f)
+		}
 
 		for _, param := range params {
 			if err := enc.Encode(param, q); err != nil {
@@ -34,6 +43,8 @@ func WithRequestQueryParams[T any](tag string, params ...T) RequestOption {
 
 // WithRequestQueryEncodableParams encodes query params by implementing ParamEncoder[T] interface,
 // calls Encode(url.Values) functional to set query params.
+// Encoders are called sequentially in order of params, each Encode receives values accumulated by
+// previous encoders (and existing query of the request), so the last write of a key wins if encoders use Set.
 func WithRequestQueryEncodableParams[T any](params ...ParamEncoder[T]) RequestOption {
 	return func(req *http.Request) error {
 		q := req.URL.Query()
@@ -47,18 +58,147 @@ func WithRequestQueryEncodableParams[T any](params ...ParamEncoder[T]) RequestOp
 	}
 }
 
+// WithRequestQueryEncodableParamsAdd is like WithRequestQueryEncodableParams, but each Encode receives empty values,
+// which are then added to the accumulated ones, so values of repeated keys accumulate instead of being overwritten.
+func WithRequestQueryEncodableParamsAdd[T any](params ...ParamEncoder[T]) RequestOption {
+	return func(req *http.Request) error {
+		q := req.URL.Query()
+		for _, param := range params {
+			v := make(url.Values)
+			if err := param.Encode(v); err != nil {
+				return fmt.Errorf("failed to encode query params: %w", err)
+			}
+			for key, vals := range v {
+				q[key] = append(q[key], vals...)
+			}
+		}
+		req.URL.RawQuery = q.Encode()
+		return nil
+	}
+}
+
+// WithRequestBodyFactory sets request body from getBody. The factory is called again for each retry attempt,
+// so the body is re-opened from its source (e.g. a file) instead of being buffered into memory.
+// Note that BeforeRequest hooks read the body fully to pass it as []byte.
+func WithRequestBodyFactory(getBody func() (io.ReadCloser, error)) RequestOption {
+	return func(req *http.Request) error {
+		body, err := getBody()
+		if err != nil {
+			return err
+		}
+		req.Body = body
+		req.GetBody = getBody
+		req.ContentLength = 0 // unknown, sent chunked
+		return nil
+	}
+}
+
+// Literal JSON bodies for WithJSONLiteralBody, some APIs require them instead of empty body.
+const (
+	JSONNull        = "null"
+	JSONEmptyArray  = "[]"
+	JSONEmptyObject = "{}"
+)
+
+// WithRequestLiteralBody sets request body to body as is, so it's sent even if empty, e.g. "null" (see JSONNull).
+// It overrides body encoded from Req. Empty contentType leaves Content-Type header untouched.
+func WithRequestLiteralBody(body []byte, contentType string) RequestOption {
+	return func(req *http.Request) error {
+		setRequestBody(req, body)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		return nil
+	}
+}
+
 func WithRequestForm(form url.Values) RequestOption {
 	return func(req *http.Request) error {
-		req.Body = io.NopCloser(strings.NewReader(form.Encode()))
+		setRequestBody(req, []byte(form.Encode()))
 		return nil
 	}
 }
 
+// WithRequestHeaders sets request headers, replacing values of headers with the same (case-insensitive) key,
+// e.g. global ones. Keys are canonicalized (see http.CanonicalHeaderKey), so "content-type" and "Content-Type"
+// result in a single header.
 func WithRequestHeaders(headers map[string][]string) RequestOption {
 	return func(req *http.Request) error {
-		for key, val := range headers {
+		for key, val := range canonicalHeader(headers) {
 			req.Header[key] = val
 		}
 		return nil
 	}
 }
+
+// canonicalHeader returns copy of headers with canonical keys. Values of keys differing only in case are merged.
+func canonicalHeader(headers map[string][]string) http.Header {
+	if headers == nil {
+		return nil
+	}
+	h := make(http.Header, len(headers))
+	for key, val := range headers {
+		key = http.CanonicalHeaderKey(key)
+		h[key] = append(h[key], val...)
+	}
+	return h
+}
+
+// WithRange sets Range header to request only part of the resource (bytes=start-end).
+// If end is negative, the range is open-ended (bytes=start-).
+func WithRange(start, end int64) RequestOption {
+	return func(req *http.Request) error {
+		if start < 0 || (end >= 0 && end < start) {
+			return fmt.Errorf("invalid range: %d-%d", start, end)
+		}
+		if end < 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", start))
+		} else {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+		}
+		return nil
+	}
+}
+
+// WithFailFastRateLimit makes request fail with ErrRateLimitExceeded immediately if rate limiter doesn't
+// allow it now, instead of waiting for a token. It's useful for latency-sensitive paths.
+// Custom limiter (see WithLimiter) has to implement AllowLimiter, otherwise request fails with ErrLimiterUnsupported.
+func WithFailFastRateLimit() RequestOption {
+	return func(req *http.Request) error {
+		*req = *req.WithContext(context.WithValue(req.Context(), failFastKey{}, true))
+		return nil
+	}
+}
+
+// WithRequestTag labels request with tag, e.g. operation="search" or tier="free". Tags aren't sent to server,
+// they are available to Observer callbacks and RateLimitKeyFunc via RequestTags (see WithTagRateLimit).
+func WithRequestTag(key, value string) RequestOption {
+	return func(req *http.Request) error {
+		old := RequestTags(req)
+		tags := make(map[string]string, len(old)+1)
+		for k, v := range old {
+			tags[k] = v
+		}
+		tags[key] = value
+		*req = *req.WithContext(context.WithValue(req.Context(), requestTagsKey{}, tags))
+		return nil
+	}
+}
+
+type requestTagsKey struct{}
+
+// RequestTags returns tags of request set by WithRequestTag, the map must not be modified.
+func RequestTags(req *http.Request) map[string]string {
+	tags, _ := req.Context().Value(requestTagsKey{}).(map[string]string)
+	return tags
+}
+
+// WithCookies adds cookies to the request.
+func WithCookies(cookies ...*http.Cookie) RequestOption {
+	return func(req *http.Request) error {
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		return nil
+	}
+}
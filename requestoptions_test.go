@@ -0,0 +1,117 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// testParams encodes its values into query with Set.
+type testParams map[string]string
+
+func (p testParams) Encode(v url.Values) error {
+	for key, val := range p {
+		v.Set(key, val)
+	}
+	return nil
+}
+
+func TestRequestQueryEncodableParams(t *testing.T) {
+	params := []ParamEncoder[testParams]{
+		testParams{"q": "go", "page": "1"},
+		testParams{"page": "2", "tag": "a"},
+		testParams{"tag": "b"},
+	}
+	tests := []struct {
+		name  string
+		query string
+		opt   RequestOption
+		want  url.Values
+	}{
+		{
+			name: "last write wins",
+			opt:  WithRequestQueryEncodableParams(params...),
+			want: url.Values{"q": {"go"}, "page": {"2"}, "tag": {"b"}},
+		},
+		{
+			name:  "last write wins over existing query",
+			query: "page=0&sort=asc",
+			opt:   WithRequestQueryEncodableParams(params...),
+			want:  url.Values{"q": {"go"}, "page": {"2"}, "tag": {"b"}, "sort": {"asc"}},
+		},
+		{
+			name: "values accumulate",
+			opt:  WithRequestQueryEncodableParamsAdd(params...),
+			want: url.Values{"q": {"go"}, "page": {"1", "2"}, "tag": {"a", "b"}},
+		},
+		{
+			name:  "values accumulate with existing query",
+			query: "page=0&sort=asc",
+			opt:   WithRequestQueryEncodableParamsAdd(params...),
+			want:  url.Values{"q": {"go"}, "page": {"0", "1", "2"}, "tag": {"a", "b"}, "sort": {"asc"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "https://example.com/items?"+tt.query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.opt(req); err != nil {
+				t.Fatal(err)
+			}
+			if got := req.URL.Query(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got query %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestHeadersCanonical(t *testing.T) {
+	tests := []struct {
+		name    string
+		global  map[string][]string
+		request map[string][]string
+		want    http.Header
+	}{
+		{
+			name:   "global keys",
+			global: map[string][]string{"content-type": {"application/json"}, "x-api-key": {"abc"}},
+			want:   http.Header{"Content-Type": {"application/json"}, "X-Api-Key": {"abc"}},
+		},
+		{
+			name:    "request replaces global case-insensitively",
+			global:  map[string][]string{"content-type": {"application/json"}},
+			request: map[string][]string{"CONTENT-TYPE": {"text/plain"}},
+			want:    http.Header{"Content-Type": {"text/plain"}},
+		},
+		{
+			name:    "keys differing only in case are merged",
+			request: map[string][]string{"x-trace": {"a"}, "X-Trace": {"b"}},
+			want:    http.Header{"X-Trace": {"a", "b"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := NewAPI(WithBaseURL("https://example.com"), WithHeaders(tt.global))
+			req, err := NewRequestBuilder[Empty, Empty](api).
+				Get("/", WithRequestHeaders(tt.request)).
+				Build(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, vals := range req.Header {
+				sort.Strings(vals) // order of merged keys isn't defined
+			}
+			if !reflect.DeepEqual(req.Header, tt.want) {
+				t.Errorf("got headers %v, want %v", req.Header, tt.want)
+			}
+		})
+	}
+}
@@ -4,38 +4,277 @@
 package clientx
 
 import (
+	"bufio"
 	"bytes"
 	"compress/flate"
 	"compress/gzip"
+	"errors"
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"strings"
 )
 
 // Empty is an empty payload for request/response decoding.
 type Empty struct{}
 
-func responseReader(resp *http.Response) (io.ReadCloser, []byte, error) {
+// ErrUnexpectedContentType is matched (errors.Is) by UnexpectedContentTypeError.
+var ErrUnexpectedContentType = errors.New("unexpected response content type")
+
+// UnexpectedContentTypeError is returned when response Content-Type doesn't match expected
+// media types (see RequestBuilder.WithExpectedContentType), e.g. HTML login page instead of JSON.
+type UnexpectedContentTypeError struct {
+	StatusCode int
+	Expected   []string
+	// Actual is a value of response Content-Type header.
+	Actual string
+	// BodySnippet contains first bytes of decompressed response body.
+	BodySnippet string
+}
+
+func (e *UnexpectedContentTypeError) Error() string {
+	return fmt.Sprintf("%s: got %q, want %s (status %d): %q", ErrUnexpectedContentType, e.Actual, strings.Join(e.Expected, " or "), e.StatusCode, e.BodySnippet)
+}
+
+func (e *UnexpectedContentTypeError) Is(target error) bool {
+	return target == ErrUnexpectedContentType
+}
+
+// ErrUnexpectedStatus is matched (errors.Is) by StatusError.
+var ErrUnexpectedStatus = errors.New("unexpected response status")
+
+// StatusError is returned when response status code isn't accepted (see WithAcceptStatus).
+type StatusError struct {
+	StatusCode int
+	Status     string
+	// BodySnippet contains first bytes of decompressed response body.
+	BodySnippet string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: %s: %q", ErrUnexpectedStatus, e.Status, e.BodySnippet)
+}
+
+func (e *StatusError) Is(target error) bool {
+	return target == ErrUnexpectedStatus
+}
+
+// checkStatus returns StatusError if status code of response isn't accepted. Any status is accepted
+// if accepted statuses aren't configured.
+func (api *API) checkStatus(resp *http.Response, body io.Reader) error {
+	if len(api.options.AcceptStatus) == 0 {
+		return nil
+	}
+	for _, r := range api.options.AcceptStatus {
+		if resp.StatusCode >= r.Min && resp.StatusCode <= r.Max {
+			return nil
+		}
+	}
+	snippet, _ := io.ReadAll(io.LimitReader(body, bodySnippetSize))
+	return &StatusError{
+		StatusCode:  resp.StatusCode,
+		Status:      resp.Status,
+		BodySnippet: string(snippet),
+	}
+}
+
+// bodySnippetSize is a maximum size of body snippet reported in errors.
+const bodySnippetSize = 256
+
+// checkContentType returns UnexpectedContentTypeError if media type of response doesn't match any of expected.
+func checkContentType(resp *http.Response, body io.Reader, expected []string) error {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	for _, ct := range expected {
+		if strings.EqualFold(ct, mediaType) {
+			return nil
+		}
+	}
+	snippet, _ := io.ReadAll(io.LimitReader(body, bodySnippetSize))
+	return &UnexpectedContentTypeError{
+		StatusCode:  resp.StatusCode,
+		Expected:    expected,
+		Actual:      resp.Header.Get("Content-Type"),
+		BodySnippet: string(snippet),
+	}
+}
+
+// responseReader buffers response body and returns reader of decompressed body.
+// If limit is positive, at most limit bytes are buffered, the rest of body is discarded.
+func (api *API) responseReader(resp *http.Response, limit int64) (io.ReadCloser, []byte, error) {
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		// Body is the upgraded connection (io.ReadWriteCloser), it must be returned untouched
+		return resp.Body, nil, nil
+	}
+	if !hasResponseBody(resp) {
+		// Nothing to buffer, but body still has to be closed to reuse connection
+		if resp.Body != nil {
+			resp.Body.Close()
+		}
+		resp.Body = http.NoBody
+		return http.NoBody, nil, nil
+	}
+
+	body := resp.Body
+	if limit > 0 && body != nil && body != http.NoBody {
+		body = readCloser{io.LimitReader(body, limit), body}
+	}
 	// Duplicate response body to two readers,
 	// the r1 we use to replace resp.Body, and r2 to build flate/gzip readers
-	r1, r2, b, err := drainBody(resp.Body)
+	r1, r2, b, err := drainBody(body)
 	if err != nil {
 		return nil, nil, err
 	}
+	resp.Body = r1
+	if len(b) == 0 {
+		// Nothing to decompress, e.g. 204 No Content labeled as gzip
+		return http.NoBody, b, nil
+	}
+
+	if !api.shouldDecompress(resp) {
+		return r2, b, nil
+	}
 
 	var reader io.ReadCloser
-	switch resp.Header.Get("Content-Encoding") {
+	switch encoding := resp.Header.Get("Content-Encoding"); encoding {
 	case "deflate":
 		reader = flate.NewReader(r2)
 	case "gzip":
 		reader, err = gzip.NewReader(r2)
+		if err != nil && api.options.DecompressFallback {
+			// Body is labeled as gzip, but it isn't, e.g. plain text error page
+			api.logger().Warn("failed to decompress response body, using raw body", "encoding", encoding, "error", err)
+			return io.NopCloser(bytes.NewReader(b)), b, nil
+		}
 	default:
 		reader = r2
 	}
-	resp.Body = r1
 
 	return reader, b, err
 }
 
+// streamReader returns reader of decompressed response body like responseReader, but body isn't buffered,
+// decompressing reader wraps live response body. Closing of the reader closes response body.
+func (api *API) streamReader(resp *http.Response, limit int64) (io.ReadCloser, error) {
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		return resp.Body, nil
+	}
+	if !hasResponseBody(resp) || resp.Body == nil || resp.Body == http.NoBody {
+		if resp.Body != nil {
+			resp.Body.Close()
+		}
+		resp.Body = http.NoBody
+		return http.NoBody, nil
+	}
+
+	var body io.Reader = resp.Body
+	if limit > 0 {
+		body = io.LimitReader(body, limit)
+	}
+	br := bufio.NewReader(body)
+	if _, err := br.Peek(1); err == io.EOF {
+		// Nothing to decompress, e.g. empty body labeled as gzip
+		resp.Body.Close()
+		return http.NoBody, nil
+	}
+	if !api.shouldDecompress(resp) {
+		return readCloser{br, resp.Body}, nil
+	}
+
+	switch encoding := resp.Header.Get("Content-Encoding"); encoding {
+	case "deflate":
+		return readCloser{flate.NewReader(br), resp.Body}, nil
+	case "gzip":
+		if magic, _ := br.Peek(2); api.options.DecompressFallback && !bytes.Equal(magic, gzipMagic) {
+			// Body is labeled as gzip, but it isn't, e.g. plain text error page
+			api.logger().Warn("failed to decompress response body, using raw body", "encoding", encoding, "error", gzip.ErrHeader)
+			return readCloser{br, resp.Body}, nil
+		}
+		reader, err := gzip.NewReader(br)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		return readCloser{reader, resp.Body}, nil
+	default:
+		return readCloser{br, resp.Body}, nil
+	}
+}
+
+// gzipMagic is a header of gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// peekBody returns decompressed response body without consuming it, resp.Body is replaced with buffered copy.
+func (api *API) peekBody(resp *http.Response) ([]byte, error) {
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		return nil, nil
+	}
+	reader, _, err := api.responseReader(resp, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// decompressBody returns raw body decompressed according to encoding (gzip or deflate).
+// Returns raw body as is if it can't be decompressed.
+func decompressBody(encoding string, raw []byte) []byte {
+	var (
+		r   io.ReadCloser
+		err error
+	)
+	switch encoding {
+	case "gzip":
+		r, err = gzip.NewReader(bytes.NewReader(raw))
+	case "deflate":
+		r = flate.NewReader(bytes.NewReader(raw))
+	default:
+		return raw
+	}
+	if err != nil {
+		return raw
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return raw
+	}
+	return b
+}
+
+// hasResponseBody reports whether response may contain body. Responses to HEAD requests
+// and 1xx (except 101, see responseReader), 204, 304 responses never have it, even if Content-Length is set (RFC 9110, section 6.4.1).
+func hasResponseBody(resp *http.Response) bool {
+	if resp.Request != nil && resp.Request.Method == http.MethodHead {
+		return false
+	}
+	switch {
+	case resp.StatusCode >= 100 && resp.StatusCode < 200,
+		resp.StatusCode == http.StatusNoContent,
+		resp.StatusCode == http.StatusNotModified:
+		return false
+	}
+	return true
+}
+
+// shouldDecompress reports whether response body has to be decompressed according to options.
+func (api *API) shouldDecompress(resp *http.Response) bool {
+	if api.options.DisableDecompression {
+		return false
+	}
+	if len(api.options.DecompressContentTypes) == 0 {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	for _, ct := range api.options.DecompressContentTypes {
+		if strings.EqualFold(ct, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
 func drainBody(r io.ReadCloser) (r1, r2 io.ReadCloser, b []byte, err error) {
 	if r == nil || r == http.NoBody {
 		// No copying needed. Preserve the magic sentinel meaning of NoBody.
@@ -51,6 +290,12 @@ func drainBody(r io.ReadCloser) (r1, r2 io.ReadCloser, b []byte, err error) {
 	return io.NopCloser(&buf), io.NopCloser(bytes.NewReader(buf.Bytes())), buf.Bytes(), nil
 }
 
+// readCloser combines reader (e.g. limited or transforming) with closer of the underlying body.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
 func decodeResponse[T any](enc EncoderDecoder, r io.ReadCloser, dst T) error {
 	return enc.Decode(r, dst)
 }
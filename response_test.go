@@ -0,0 +1,196 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestResponse returns response to request with method, body is sent as is.
+func newTestResponse(method string, status int, header http.Header, body string) *http.Response {
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    &http.Request{Method: method},
+	}
+}
+
+func gzipString(t *testing.T, s string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestResponseReaderGzip(t *testing.T) {
+	gzipped := http.Header{"Content-Encoding": {"gzip"}}
+	tests := []struct {
+		name     string
+		opts     []Option
+		body     string
+		want     string
+		wantErr  bool
+		wantBody bool // whether reader isn't http.NoBody
+	}{
+		{name: "gzip", body: gzipString(t, "hello"), want: "hello", wantBody: true},
+		{name: "empty gzip", body: "", want: ""},
+		{name: "mislabeled gzip", body: "plain error", wantErr: true},
+		{name: "mislabeled gzip with fallback", opts: []Option{WithDecompressFallback()}, body: "plain error", want: "plain error", wantBody: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := NewAPI(tt.opts...)
+			resp := newTestResponse(http.MethodGet, http.StatusOK, gzipped.Clone(), tt.body)
+			reader, _, err := api.responseReader(resp, 0)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("got no error, want decompression error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := reader != http.NoBody; got != tt.wantBody {
+				t.Errorf("got non-empty reader %v, want %v", got, tt.wantBody)
+			}
+			got, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got body %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseReaderNoBody(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		status int
+		header http.Header
+		body   string
+	}{
+		{"HEAD with Content-Length", http.MethodHead, http.StatusOK, http.Header{"Content-Length": {"42"}}, "not a body"},
+		{"204 No Content", http.MethodGet, http.StatusNoContent, nil, ""},
+		{"204 labeled as gzip", http.MethodDelete, http.StatusNoContent, http.Header{"Content-Encoding": {"gzip"}}, ""},
+		{"304 Not Modified", http.MethodGet, http.StatusNotModified, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := newTestResponse(tt.method, tt.status, tt.header, tt.body)
+			reader, b, err := NewAPI().responseReader(resp, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reader != http.NoBody || resp.Body != http.NoBody {
+				t.Errorf("got reader %T and body %T, want http.NoBody", reader, resp.Body)
+			}
+			if len(b) != 0 {
+				t.Errorf("got buffered body %q, want empty", b)
+			}
+		})
+	}
+}
+
+func TestDecodeEmptyBody(t *testing.T) {
+	type item struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	tests := []struct {
+		name   string
+		status int
+		header http.Header
+	}{
+		{"empty 200", http.StatusOK, nil},
+		{"empty 200 labeled as gzip", http.StatusOK, http.Header{"Content-Encoding": {"gzip"}}},
+		{"empty 200 labeled as deflate", http.StatusOK, http.Header{"Content-Encoding": {"deflate"}}},
+		{"204 labeled as gzip", http.StatusNoContent, http.Header{"Content-Encoding": {"gzip"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for key, vals := range tt.header {
+					w.Header()[key] = vals
+				}
+				w.Header().Set("Content-Length", "0")
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			api := NewAPI(WithBaseURL(srv.URL))
+			got, err := NewRequestBuilder[Empty, item](api).Get("/items/1").DoWithDecode(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *got != (item{}) {
+				t.Errorf("got %+v, want zero value", *got)
+			}
+		})
+	}
+}
+
+func TestResponseErrorKeepsResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        []Option
+		contentType []string
+		status      int
+		wantErr     any
+	}{
+		{"unexpected status", []Option{WithAcceptStatus(200, 299)}, nil, http.StatusBadGateway, new(*StatusError)},
+		{"unexpected content type", nil, []string{"application/json"}, http.StatusOK, new(*UnexpectedContentTypeError)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				w.Header().Set("X-Request-Id", "abc")
+				w.WriteHeader(tt.status)
+				io.WriteString(w, "<html>error page</html>")
+			}))
+			defer srv.Close()
+
+			api := NewAPI(append([]Option{WithBaseURL(srv.URL)}, tt.opts...)...)
+			resp, err := NewRequestBuilder[Empty, Empty](api).
+				Get("/").
+				WithExpectedContentType(tt.contentType...).
+				Do(context.Background())
+			if !errors.As(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %T", err, tt.wantErr)
+			}
+			if resp == nil {
+				t.Fatal("got nil response, want response with status and headers")
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.status || resp.Header.Get("X-Request-Id") != "abc" {
+				t.Errorf("got status %d and X-Request-Id %q, want %d and %q", resp.StatusCode, resp.Header.Get("X-Request-Id"), tt.status, "abc")
+			}
+			if b, err := io.ReadAll(resp.Body); err != nil || string(b) != "<html>error page</html>" {
+				t.Errorf("got body (%q, %v), want buffered body", b, err)
+			}
+		})
+	}
+}
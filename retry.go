@@ -4,9 +4,15 @@
 package clientx
 
 import (
+	"bytes"
+	"context"
+	"io"
 	"math"
 	"math/rand"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -14,17 +20,114 @@ import (
 // RetryCond is a condition that applies only to retry backoff mechanism.
 type RetryCond func(resp *http.Response, err error) bool
 
+// RetryOnBodyMatch returns RetryCond which triggers retry when match reports true for response body,
+// e.g. for soft rate limits signalled by 200 status and {"error":"rate_limited"} body.
+// The body is buffered (and decompressed according to Content-Encoding for matching),
+// so it's still available for decoding if the request isn't retried.
+func RetryOnBodyMatch(match func(body []byte) bool) RetryCond {
+	return func(resp *http.Response, err error) bool {
+		if err != nil || resp == nil || resp.Body == nil || resp.Body == http.NoBody {
+			return false
+		}
+		raw, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(raw))
+		if err != nil {
+			return false
+		}
+		return match(decompressBody(resp.Header.Get("Content-Encoding"), raw))
+	}
+}
+
+// RetryGuard is checked before each retry attempt, after retry condition is matched and wait duration is computed.
+// Returns false to stop retrying, e.g. when the attempt can't finish before context deadline.
+// Unlike conditions (any of them triggers retry), all guards have to allow the retry.
+type RetryGuard func(ctx context.Context, resp *http.Response, err error, wait time.Duration) bool
+
+// RetryIfTimeLeft returns RetryGuard which allows retry only if at least minRemaining is left
+// before context deadline after waiting for the next attempt. Allows retry if context has no deadline.
+func RetryIfTimeLeft(minRemaining time.Duration) RetryGuard {
+	return func(ctx context.Context, _ *http.Response, _ error, wait time.Duration) bool {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			return true
+		}
+		return time.Until(deadline) >= wait+minRemaining
+	}
+}
+
+// RetryIfHeaderAllows returns RetryGuard which respects provider retry guidance sent in response header,
+// e.g. RetryIfHeaderAllows("Stripe-Should-Retry"): header value "false" stops retrying even if retry
+// condition matched. Allows retry if response or header is missing.
+func RetryIfHeaderAllows(header string) RetryGuard {
+	return func(_ context.Context, resp *http.Response, _ error, _ time.Duration) bool {
+		if resp == nil {
+			return true
+		}
+		allow, err := strconv.ParseBool(strings.TrimSpace(resp.Header.Get(header)))
+		return err != nil || allow
+	}
+}
+
+// RetryDelayFunc extracts retry delay from response and its decompressed body.
+// Returns false if response has no delay hint, so it isn't retried because of it.
+type RetryDelayFunc func(resp *http.Response, body []byte) (time.Duration, bool)
+
 // RetryFunc takes attemps number, minimal and maximal wait time for backoff.
 // Returns duration that mechanism have to wait before making a request.
 type RetryFunc func(n int, min, max time.Duration) time.Duration
 
 // Retrier is a general interface for custom retry algo implementations.
+// Next returns wait duration before the next attempt or StopBackoff.
 type Retrier interface {
 	Next() time.Duration
 	Reset() int64
 	Attempt() int64
 }
 
+// Jitter randomizes delay computed by RetryFunc, min and max are minimal and maximal wait time of retries.
+// Implementations must be safe for concurrent use.
+type Jitter interface {
+	Apply(delay, min, max time.Duration) time.Duration
+}
+
+// JitterFunc is an adapter to use ordinary function as Jitter.
+type JitterFunc func(delay, min, max time.Duration) time.Duration
+
+func (f JitterFunc) Apply(delay, min, max time.Duration) time.Duration {
+	return f(delay, min, max)
+}
+
+var (
+	// FullJitter randomizes delay uniformly between min and delay.
+	FullJitter = NewFullJitter()
+	// EqualJitter keeps half of delay and randomizes the other half, delay never goes below min.
+	EqualJitter = NewEqualJitter()
+	// NoJitter returns delay unchanged.
+	NoJitter Jitter = JitterFunc(func(delay, _, _ time.Duration) time.Duration { return delay })
+)
+
+// NewFullJitter returns FullJitter with applied options, e.g. BackoffRandSource.
+// See https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+func NewFullJitter(opts ...BackoffOption) Jitter {
+	o := newBackoffOptions(opts)
+	return JitterFunc(func(delay, min, max time.Duration) time.Duration {
+		if delay <= min {
+			return clampDuration(delay, min, max)
+		}
+		return clampDuration(min+time.Duration(o.rand()*float64(delay-min)), min, max)
+	})
+}
+
+// NewEqualJitter returns EqualJitter with applied options, e.g. BackoffRandSource.
+func NewEqualJitter(opts ...BackoffOption) Jitter {
+	o := newBackoffOptions(opts)
+	return JitterFunc(func(delay, min, max time.Duration) time.Duration {
+		half := delay / 2
+		return clampDuration(half+time.Duration(o.rand()*float64(half)), min, max)
+	})
+}
+
 // backoff is a thread-safe retry backoff mechanism.
 // Currently supported only ExponentalBackoff retry algorithm.
 type backoff struct {
@@ -33,18 +136,24 @@ type backoff struct {
 	maxAttempts int64
 	attempts    int64
 	f           RetryFunc
+	jitter      Jitter
 }
 
 var _ Retrier = (*backoff)(nil)
 
-const stopBackoff time.Duration = -1
+// StopBackoff is returned by Retrier.Next when no more attempts are allowed.
+const StopBackoff time.Duration = -1
 
 func (b *backoff) Next() time.Duration {
 	if atomic.LoadInt64(&b.attempts) >= b.maxAttempts {
-		return stopBackoff
+		return StopBackoff
 	}
 	atomic.AddInt64(&b.attempts, 1)
-	return b.f(int(atomic.LoadInt64(&b.attempts)), b.minWaitTime, b.maxWaitTime)
+	delay := b.f(int(atomic.LoadInt64(&b.attempts)), b.minWaitTime, b.maxWaitTime)
+	if b.jitter != nil {
+		delay = b.jitter.Apply(delay, b.minWaitTime, b.maxWaitTime)
+	}
+	return delay
 }
 
 func (b *backoff) Reset() int64 {
@@ -55,11 +164,228 @@ func (b *backoff) Attempt() int64 {
 	return atomic.LoadInt64(&b.attempts)
 }
 
+// RetryStopReason describes why retrying of a request was stopped.
+type RetryStopReason int
+
+const (
+	// RetryNotStopped means that retry conditions didn't match the last attempt, e.g. it succeeded.
+	RetryNotStopped RetryStopReason = iota
+	// RetryStopMaxAttempts means that Retrier returned StopBackoff, e.g. MaxAttempts is reached.
+	RetryStopMaxAttempts
+	// RetryStopGuard means that one of RetryGuard disallowed the retry, e.g. elapsed budget is exhausted (RetryIfTimeLeft).
+	RetryStopGuard
+	// RetryStopContext means that context was done while waiting for the next attempt.
+	RetryStopContext
+)
+
+func (r RetryStopReason) String() string {
+	switch r {
+	case RetryNotStopped:
+		return "not stopped"
+	case RetryStopMaxAttempts:
+		return "max attempts"
+	case RetryStopGuard:
+		return "guard"
+	case RetryStopContext:
+		return "context done"
+	default:
+		return "unknown"
+	}
+}
+
+// RetryStats describes retry sequence of a request (see RequestBuilder.CaptureRetryStats).
+type RetryStats struct {
+	// Attempts is a number of performed attempts, including the first one.
+	Attempts int
+	// TotalWait is a total time spent waiting between attempts.
+	TotalWait time.Duration
+	// StopReason is a reason why the last retry sequence was stopped.
+	StopReason RetryStopReason
+}
+
+// add accumulates stats of the next retry sequence (e.g. against failover URL).
+func (s *RetryStats) add(next RetryStats) {
+	s.Attempts += next.Attempts
+	s.TotalWait += next.TotalWait
+	s.StopReason = next.StopReason
+}
+
+// IdempotencyKeyHeader is a header that makes POST and PATCH requests safe to retry (see WithRetryIdempotentOnly).
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// allowRetry reports whether all guards allow to retry.
+func (o *OptionRetry) allowRetry(ctx context.Context, resp *http.Response, err error, wait time.Duration) bool {
+	for _, guard := range o.Guards {
+		if !guard(ctx, resp, err, wait) {
+			return false
+		}
+	}
+	return true
+}
+
+// delayHint returns retry delay extracted from response body by DelayFn, bounded by MaxWaitTime.
+func (o *OptionRetry) delayHint(api *API, resp *http.Response) (time.Duration, bool) {
+	if o.DelayFn == nil || resp == nil {
+		return 0, false
+	}
+	body, err := api.peekBody(resp)
+	if err != nil {
+		return 0, false
+	}
+	delay, ok := o.DelayFn(resp, body)
+	if !ok {
+		return 0, false
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	if o.MaxWaitTime > 0 && delay > o.MaxWaitTime {
+		delay = o.MaxWaitTime
+	}
+	return delay, true
+}
+
+// retryAfter returns delay from Retry-After header of response if RespectRetryAfter is set, bounded by MaxWaitTime.
+// Returns false if header is missing or malformed, date in the past means zero delay.
+func (o *OptionRetry) retryAfter(resp *http.Response) (time.Duration, bool) {
+	if !o.RespectRetryAfter || resp == nil {
+		return 0, false
+	}
+	delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	if !ok {
+		return 0, false
+	}
+	if o.MaxWaitTime > 0 && delay > o.MaxWaitTime {
+		delay = o.MaxWaitTime
+	}
+	return delay, true
+}
+
+// isRetryable reports whether request may be retried according to retry options.
+func (o *OptionRetry) isRetryable(req *http.Request) bool {
+	if !o.IdempotentOnly {
+		return true
+	}
+	switch req.Method {
+	case http.MethodPost, http.MethodPatch:
+		return req.Header.Get(IdempotencyKeyHeader) != ""
+	default:
+		return true
+	}
+}
+
+// ExponentalBackoffRelativeJitter returns exponential RetryFunc, which randomizes computed delay
+// by at most ±fraction of it (e.g. 0.2 means ±20%), instead of adding attempt-scaled jitter.
+// Fraction is clamped into [0, 1]. Resulting delay never exceeds max and never goes below min.
+func ExponentalBackoffRelativeJitter(fraction float64, opts ...BackoffOption) RetryFunc {
+	fraction = math.Max(0, math.Min(fraction, 1))
+	o := newBackoffOptions(opts)
+	return func(attemptNum int, min, max time.Duration) time.Duration {
+		const factor = 2.0
+		delay := math.Min(math.Pow(factor, float64(attemptNum))*float64(min), float64(max))
+		delay += (o.rand()*2 - 1) * fraction * delay
+		return clampDuration(time.Duration(delay), min, max)
+	}
+}
+
+// clampDuration returns d limited to [min, max] range.
+func clampDuration(d, min, max time.Duration) time.Duration {
+	if d > max {
+		d = max
+	}
+	if d < min {
+		d = min
+	}
+	return d
+}
+
+type (
+	// BackoffOption configures built-in RetryFunc implementations.
+	BackoffOption  func(*backoffOptions)
+	backoffOptions struct {
+		rand        func() float64
+		maxAttempts int
+	}
+)
+
+func newBackoffOptions(opts []BackoffOption) *backoffOptions {
+	o := &backoffOptions{
+		rand: rand.Float64, // global source is safe for concurrent use and seeded once
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// BackoffRand sets source of randomness used for jitter. f must return values in [0, 1)
+// and be safe for concurrent use, e.g. to make jitter deterministic in tests.
+func BackoffRand(f func() float64) BackoffOption {
+	return func(o *backoffOptions) {
+		o.rand = f
+	}
+}
+
+// BackoffMaxAttempts sets maximum number of retries allowed by stateful Retrier implementations,
+// e.g. NewDecorrelatedJitter. Zero means no limit, so retries continue while retry conditions match.
+func BackoffMaxAttempts(n int) BackoffOption {
+	return func(o *backoffOptions) {
+		o.maxAttempts = n
+	}
+}
+
+// BackoffRandSource sets seedable source of randomness used for jitter.
+// Access to r is serialized, because *rand.Rand isn't safe for concurrent use.
+func BackoffRandSource(r *rand.Rand) BackoffOption {
+	var mu sync.Mutex
+	return BackoffRand(func() float64 {
+		mu.Lock()
+		defer mu.Unlock()
+		return r.Float64()
+	})
+}
+
+// NewExponentalBackoff returns ExponentalBackoff with applied options, e.g. BackoffRandSource.
+func NewExponentalBackoff(opts ...BackoffOption) RetryFunc {
+	o := newBackoffOptions(opts)
+	return func(attemptNum int, min, max time.Duration) time.Duration {
+		return exponentalBackoff(o.rand, attemptNum, min, max)
+	}
+}
+
 func ExponentalBackoff(attemptNum int, min, max time.Duration) time.Duration {
+	return exponentalBackoff(rand.Float64, attemptNum, min, max)
+}
+
+func exponentalBackoff(rnd func() float64, attemptNum int, min, max time.Duration) time.Duration {
 	const factor = 2.0
-	rand.Seed(time.Now().UnixNano())
-	delay := time.Duration(math.Pow(factor, float64(attemptNum)) * float64(min))
-	jitter := time.Duration(rand.Float64() * float64(min) * float64(attemptNum))
+	// Compute in float64, so large attempt numbers don't overflow time.Duration
+	delay := math.Pow(factor, float64(attemptNum))*float64(min) + rnd()*float64(min)*float64(attemptNum)
+	if delay > float64(max) {
+		return max
+	}
+
+	return time.Duration(delay)
+}
+
+// NewLinearBackoff returns LinearBackoff with applied options, e.g. BackoffRand(func() float64 { return 0 })
+// to disable jitter.
+func NewLinearBackoff(opts ...BackoffOption) RetryFunc {
+	o := newBackoffOptions(opts)
+	return func(attemptNum int, min, max time.Duration) time.Duration {
+		return linearBackoff(o.rand, attemptNum, min, max)
+	}
+}
+
+// LinearBackoff increases delay linearly (min, 2*min, 3*min, ...) and adds jitter less than min,
+// so delays still grow monotonically. Delay never exceeds max.
+func LinearBackoff(attemptNum int, min, max time.Duration) time.Duration {
+	return linearBackoff(rand.Float64, attemptNum, min, max)
+}
+
+func linearBackoff(rnd func() float64, attemptNum int, min, max time.Duration) time.Duration {
+	delay := time.Duration(attemptNum) * min
+	jitter := time.Duration(rnd() * float64(min))
 
 	delay = delay + jitter
 	if delay > max {
@@ -68,3 +394,78 @@ func ExponentalBackoff(attemptNum int, min, max time.Duration) time.Duration {
 
 	return delay
 }
+
+// ConstantBackoff returns the same delay (min) regardless of attempt number, e.g. for polling endpoints.
+// Delay never exceeds max.
+func ConstantBackoff(attemptNum int, min, max time.Duration) time.Duration {
+	if min > max {
+		return max
+	}
+	return min
+}
+
+// decorrelatedJitter is a thread-safe Retrier implementing "decorrelated jitter" algorithm, see
+// https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+type decorrelatedJitter struct {
+	mu       sync.Mutex
+	min, max time.Duration
+	prev     time.Duration
+	attempts int64
+	opts     *backoffOptions
+}
+
+var _ Retrier = (*decorrelatedJitter)(nil)
+
+// NewDecorrelatedJitter returns Retrier which computes sleep = min(max, random_between(min, prev*3)),
+// where prev is the previous sleep (min for the first retry). Unlike additive jitter, delays of many clients
+// don't synchronize. At most defaultMaxAttempts (5) retries are allowed unless BackoffMaxAttempts is set,
+// use it with WithRetrier:
+//
+//	clientx.WithRetrier(func() clientx.Retrier {
+//		return clientx.NewDecorrelatedJitter(time.Second, time.Minute, clientx.BackoffMaxAttempts(10))
+//	})
+func NewDecorrelatedJitter(min, max time.Duration, opts ...BackoffOption) Retrier {
+	opts = append([]BackoffOption{BackoffMaxAttempts(defaultMaxAttempts)}, opts...)
+	return &decorrelatedJitter{
+		min:  min,
+		max:  max,
+		opts: newBackoffOptions(opts),
+	}
+}
+
+// defaultMaxAttempts is a maximum number of retries of stateful Retrier implementations
+// if BackoffMaxAttempts isn't set.
+const defaultMaxAttempts = 5
+
+func (b *decorrelatedJitter) Next() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.opts.maxAttempts > 0 && b.attempts >= int64(b.opts.maxAttempts) {
+		return StopBackoff
+	}
+	b.attempts++
+
+	prev := b.prev
+	if prev < b.min {
+		prev = b.min
+	}
+	upper := 3 * prev
+	sleep := b.min + time.Duration(b.opts.rand()*float64(upper-b.min))
+	b.prev = clampDuration(sleep, b.min, b.max)
+	return b.prev
+}
+
+func (b *decorrelatedJitter) Reset() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := b.attempts
+	b.attempts = 0
+	b.prev = 0
+	return n
+}
+
+func (b *decorrelatedJitter) Attempt() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.attempts
+}
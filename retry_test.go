@@ -0,0 +1,224 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryGuard(t *testing.T) {
+	const maxAttempts = 3
+	tests := []struct {
+		name         string
+		header       string
+		guard        RetryGuard
+		wantAttempts int
+		wantReason   RetryStopReason
+	}{
+		{
+			name: "allowed",
+			guard: func(context.Context, *http.Response, error, time.Duration) bool {
+				return true
+			},
+			wantAttempts: maxAttempts + 1,
+			wantReason:   RetryStopMaxAttempts,
+		},
+		{
+			name: "rejected",
+			guard: func(context.Context, *http.Response, error, time.Duration) bool {
+				return false
+			},
+			wantAttempts: 1,
+			wantReason:   RetryStopGuard,
+		},
+		{
+			name:         "header allows",
+			header:       "true",
+			guard:        RetryIfHeaderAllows("X-Should-Retry"),
+			wantAttempts: maxAttempts + 1,
+			wantReason:   RetryStopMaxAttempts,
+		},
+		{
+			name:         "header rejects",
+			header:       "false",
+			guard:        RetryIfHeaderAllows("X-Should-Retry"),
+			wantAttempts: 1,
+			wantReason:   RetryStopGuard,
+		},
+		{
+			name:         "no time left",
+			guard:        RetryIfTimeLeft(time.Hour),
+			wantAttempts: 1,
+			wantReason:   RetryStopGuard,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.header != "" {
+					w.Header().Set("X-Should-Retry", tt.header)
+				}
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer srv.Close()
+
+			api := NewAPI(
+				WithBaseURL(srv.URL),
+				WithRetry(maxAttempts, time.Millisecond, time.Millisecond, ConstantBackoff, func(resp *http.Response, err error) bool {
+					return resp != nil && resp.StatusCode == http.StatusServiceUnavailable
+				}),
+				WithRetryGuards(tt.guard),
+			)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			var stats RetryStats
+			resp, err := NewRequestBuilder[Empty, Empty](api).Get("/").CaptureRetryStats(&stats).Do(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if stats.Attempts != tt.wantAttempts || stats.StopReason != tt.wantReason {
+				t.Errorf("got %d attempts (%s), want %d (%s)", stats.Attempts, stats.StopReason, tt.wantAttempts, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestDecorrelatedJitter(t *testing.T) {
+	const (
+		min = 10 * time.Millisecond
+		max = time.Second
+	)
+	seen := make(map[time.Duration]bool)
+	for run := 0; run < 10; run++ {
+		r := NewDecorrelatedJitter(min, max, BackoffMaxAttempts(50))
+		for i := 0; i < 50; i++ {
+			d := r.Next()
+			if d < min || d > max {
+				t.Fatalf("run %d, attempt %d: delay %v is out of [%v, %v]", run, i+1, d, min, max)
+			}
+			seen[d] = true
+		}
+		if d := r.Next(); d != StopBackoff {
+			t.Fatalf("run %d: got %v after max attempts, want StopBackoff", run, d)
+		}
+	}
+	if len(seen) < 2 {
+		t.Errorf("delays don't vary: %v", seen)
+	}
+}
+
+func TestDecorrelatedJitterDefaultMaxAttempts(t *testing.T) {
+	r := NewDecorrelatedJitter(time.Millisecond, time.Second)
+	for i := 0; i < defaultMaxAttempts; i++ {
+		if d := r.Next(); d == StopBackoff {
+			t.Fatalf("attempt %d: got StopBackoff, want %d attempts", i+1, defaultMaxAttempts)
+		}
+	}
+	if d := r.Next(); d != StopBackoff {
+		t.Errorf("got %v after %d attempts, want StopBackoff", d, defaultMaxAttempts)
+	}
+}
+
+func TestBackoffMonotonic(t *testing.T) {
+	const (
+		min = 10 * time.Millisecond
+		max = time.Minute
+	)
+	tests := []struct {
+		name string
+		f    RetryFunc
+	}{
+		{"exponential", ExponentalBackoff},
+		{"linear", LinearBackoff},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var prev time.Duration
+			for n := 1; n <= 50; n++ {
+				d := tt.f(n, min, max)
+				if d > max {
+					t.Fatalf("attempt %d: delay %v exceeds max %v", n, d, max)
+				}
+				if d < prev {
+					t.Fatalf("attempt %d: delay %v is less than previous %v", n, d, prev)
+				}
+				prev = d
+			}
+		})
+	}
+}
+
+func TestBackoffAttempts(t *testing.T) {
+	const (
+		min = 100 * time.Millisecond
+		max = 550 * time.Millisecond
+	)
+	noJitter := BackoffRand(func() float64 { return 0 })
+	tests := []struct {
+		name string
+		f    RetryFunc
+		want func(n int) time.Duration
+	}{
+		{
+			name: "constant",
+			f:    ConstantBackoff,
+			want: func(int) time.Duration { return min },
+		},
+		{
+			name: "linear",
+			f:    NewLinearBackoff(noJitter),
+			want: func(n int) time.Duration {
+				if d := time.Duration(n) * min; d < max {
+					return d
+				}
+				return max
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for n := 1; n <= 10; n++ {
+				if got, want := tt.f(n, min, max), tt.want(n); got != want {
+					t.Errorf("attempt %d: got %v, want %v", n, got, want)
+				}
+			}
+		})
+	}
+	if got := ConstantBackoff(1, time.Second, min); got != min {
+		t.Errorf("constant backoff with min > max: got %v, want %v", got, min)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   OptionRetry
+		header string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"not respected", OptionRetry{}, "10", 0, false},
+		{"delta-seconds", OptionRetry{RespectRetryAfter: true}, "10", 10 * time.Second, true},
+		{"past date", OptionRetry{RespectRetryAfter: true}, time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+		{"malformed", OptionRetry{RespectRetryAfter: true}, "later", 0, false},
+		{"negative", OptionRetry{RespectRetryAfter: true}, "-1", 0, false},
+		{"bounded by MaxWaitTime", OptionRetry{RespectRetryAfter: true, MaxWaitTime: time.Second}, "10", time.Second, true},
+		{"overflow bounded by MaxWaitTime", OptionRetry{RespectRetryAfter: true, MaxWaitTime: time.Second}, "99999999999999999999", time.Second, true},
+		{"overflow is clamped", OptionRetry{RespectRetryAfter: true}, "99999999999999999999", maxRetryAfter, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{"Retry-After": {tt.header}}}
+			got, ok := tt.opts.retryAfter(resp)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("got (%v, %v), want (%v, %v)", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
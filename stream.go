@@ -0,0 +1,173 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DoStream executes request and returns response with reader of decompressed response body, which must be closed
+// by caller. If there are no AfterResponse hooks (they receive body bytes), the body isn't buffered into memory,
+// e.g. gzip reader wraps live response body, so large compressed downloads are streamed. Response body limit
+// (see WithResponseBodyLimit) is respected.
+func (rb *RequestBuilder[Req, Resp]) DoStream(ctx context.Context, enc ...EncoderDecoder) (_ *http.Response, _ io.ReadCloser, err error) {
+	e, err := rb.client.api.selectEncoderDecoder(enc)
+	if err != nil {
+		rb.finish(nil, err, RetryStats{})
+		return nil, nil, err
+	}
+
+	ctx, cancel := rb.client.api.operationContext(ctx)
+	httpResp, reader, stats, err := rb.client.send(ctx, rb, e, rb.bodyLimit, true)
+	defer func() {
+		rb.finish(httpResp, err, stats)
+	}()
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	if rb.errDecodeFn != nil {
+		if ok, err := rb.errDecodeFn(httpResp); ok {
+			reader.Close()
+			cancel()
+			return nil, nil, err
+		}
+	}
+	if err := rb.client.api.checkStatus(httpResp, reader); err != nil {
+		reader.Close()
+		cancel()
+		return nil, nil, err
+	}
+	if err := rb.checkContentType(httpResp, reader); err != nil {
+		reader.Close()
+		cancel()
+		return nil, nil, err
+	}
+	return httpResp, cancelReadCloser{reader, cancel}, nil
+}
+
+// DoIntoWriter executes request and copies decompressed response body into w without buffering it (see DoStream).
+// Returns number of bytes written to w.
+func (rb *RequestBuilder[Req, Resp]) DoIntoWriter(ctx context.Context, w io.Writer, enc ...EncoderDecoder) (int64, error) {
+	_, reader, err := rb.DoStream(ctx, enc...)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+	return io.Copy(w, reader)
+}
+
+// cancelReadCloser cancels context of request when body is closed.
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (r cancelReadCloser) Close() error {
+	defer r.cancel()
+	return r.ReadCloser.Close()
+}
+
+// DoEach executes request, expects response to be a JSON array and decodes it element by element,
+// calling f for each decoded Resp. Unlike DoWithDecode, the whole array is never unmarshalled into memory,
+// so it suits endpoints returning huge arrays. Iteration stops on the first error returned by f.
+// Request body is encoded with the default EncoderDecoder (see WithDefaultEncoderDecoder).
+func (rb *RequestBuilder[Req, Resp]) DoEach(ctx context.Context, f func(item *Resp) error) (err error) {
+	ctx, cancel := rb.client.api.operationContext(ctx)
+	defer cancel()
+
+	httpResp, reader, stats, err := rb.client.send(ctx, rb, rb.client.api.defaultEncoderDecoder(), 0, true)
+	defer func() {
+		rb.finish(httpResp, err, stats)
+	}()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if rb.errDecodeFn != nil {
+		if ok, err := rb.errDecodeFn(httpResp); ok {
+			return err
+		}
+	}
+	if err := rb.client.api.checkStatus(httpResp, reader); err != nil {
+		return err
+	}
+	if err := rb.checkContentType(httpResp, reader); err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(reader)
+	if err := expectDelim(dec, '['); err != nil {
+		return err
+	}
+	for dec.More() {
+		var item Resp
+		if err := dec.Decode(&item); err != nil {
+			return err
+		}
+		if err := f(&item); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+// DoScan executes request and splits decompressed response body into tokens by split (bufio.ScanLines if it's nil),
+// calling handle for each token, e.g. for streaming protocols with custom framing. The body isn't buffered
+// into memory, but a single token can't exceed bufio.MaxScanTokenSize. The token slice is valid only until
+// handle returns. Iteration stops on the first error returned by handle.
+func (rb *RequestBuilder[Req, Resp]) DoScan(ctx context.Context, split bufio.SplitFunc, handle func(token []byte) error) (err error) {
+	ctx, cancel := rb.client.api.operationContext(ctx)
+	defer cancel()
+
+	httpResp, reader, stats, err := rb.client.send(ctx, rb, rb.client.api.defaultEncoderDecoder(), 0, true)
+	defer func() {
+		rb.finish(httpResp, err, stats)
+	}()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if rb.errDecodeFn != nil {
+		if ok, err := rb.errDecodeFn(httpResp); ok {
+			return err
+		}
+	}
+	if err := rb.client.api.checkStatus(httpResp, reader); err != nil {
+		return err
+	}
+	if err := rb.checkContentType(httpResp, reader); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(reader)
+	if split != nil {
+		scanner.Split(split)
+	}
+	for scanner.Scan() {
+		if err := handle(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("unexpected JSON token %v, want %v", tok, delim)
+	}
+	return nil
+}
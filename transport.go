@@ -0,0 +1,216 @@
+// Copyright (c) 2024 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package clientx
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TransportOption tunes *http.Transport that clientx builds for the API.
+type TransportOption func(t *http.Transport)
+
+// buildHTTPClient returns copy of client with cloned transport and applied transport options.
+// The original client (e.g. http.DefaultClient) and its transport are never modified.
+// If client uses custom http.RoundTripper which isn't *http.Transport, it's returned as is.
+func buildHTTPClient(client *http.Client, opts []TransportOption) *http.Client {
+	if len(opts) == 0 {
+		return client
+	}
+
+	var transport *http.Transport
+	switch t := client.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		return client
+	}
+	for _, opt := range opts {
+		opt(transport)
+	}
+
+	c := *client
+	c.Transport = transport
+	return &c
+}
+
+// WithConnectionAffinity pins all requests to a single keep-alive connection per host (MaxConnsPerHost=1),
+// so a sequence of calls is issued in order over the same HTTP/1.1 connection. It's useful for stateful
+// APIs that key on connection. Concurrent requests wait for the connection to become idle.
+// Has no effect if custom http.Client uses a transport other than *http.Transport.
+func WithConnectionAffinity() Option {
+	return WithTransportOptions(func(t *http.Transport) {
+		t.MaxConnsPerHost = 1
+		t.MaxIdleConnsPerHost = 1
+		t.DisableKeepAlives = false
+		t.ForceAttemptHTTP2 = false
+	})
+}
+
+// WithTransportOptions adds options that tune transport of HTTP client. The transport is cloned,
+// so neither http.DefaultTransport nor transport of client passed by WithHTTPClient is modified.
+func WithTransportOptions(opts ...TransportOption) Option {
+	return func(o *Options) {
+		o.TransportOptions = append(o.TransportOptions, opts...)
+	}
+}
+
+// WithIdleConnTimeout sets maximum amount of time an idle keep-alive connection remains open.
+func WithIdleConnTimeout(d time.Duration) Option {
+	return WithTransportOptions(func(t *http.Transport) {
+		t.IdleConnTimeout = d
+	})
+}
+
+// WithMaxIdleConns sets maximum number of idle keep-alive connections in total and per host.
+// Zero means no limit for maxIdle and http.DefaultMaxIdleConnsPerHost for maxIdlePerHost.
+func WithMaxIdleConns(maxIdle, maxIdlePerHost int) Option {
+	return WithTransportOptions(func(t *http.Transport) {
+		t.MaxIdleConns = maxIdle
+		t.MaxIdleConnsPerHost = maxIdlePerHost
+	})
+}
+
+// WithDialTimeout sets maximum amount of time to establish TCP connection. Timed out dial is reported
+// as *net.OpError with Op "dial", so it's distinguishable from a server that accepted but never responded.
+// It replaces DialContext of the transport with net.Dialer using default keep-alive period.
+func WithDialTimeout(d time.Duration) Option {
+	return WithTransportOptions(func(t *http.Transport) {
+		dialer := &net.Dialer{
+			Timeout:   d,
+			KeepAlive: 30 * time.Second,
+		}
+		t.DialContext = dialer.DialContext
+	})
+}
+
+// WithTLSHandshakeTimeout sets maximum amount of time to wait for TLS handshake. Zero means no timeout.
+func WithTLSHandshakeTimeout(d time.Duration) Option {
+	return WithTransportOptions(func(t *http.Transport) {
+		t.TLSHandshakeTimeout = d
+	})
+}
+
+// WithTLSServerName sets server name used for SNI and verification of server certificate, e.g. when BaseURL
+// is an IP address of specific backend instance, but its certificate is issued for a hostname.
+// Note that Host header of requests still contains host of BaseURL.
+func WithTLSServerName(name string) Option {
+	return WithTransportOptions(func(t *http.Transport) {
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{}
+		}
+		t.TLSClientConfig.ServerName = name
+	})
+}
+
+// WithResponseHeaderTimeout sets maximum amount of time to wait for response headers after request
+// is fully written (including body). It doesn't limit time to read response body. Zero means no timeout.
+func WithResponseHeaderTimeout(d time.Duration) Option {
+	return WithTransportOptions(func(t *http.Transport) {
+		t.ResponseHeaderTimeout = d
+	})
+}
+
+// WithConnPoolStats enables tracking of connection pool usage, see API.ConnPoolStats.
+// Has no effect if custom http.Client uses a transport other than *http.Transport.
+func WithConnPoolStats() Option {
+	return func(o *Options) {
+		o.ConnPoolStats = true
+	}
+}
+
+// ConnPoolStats is a snapshot of connection pool usage. Values are approximate and
+// reliable for HTTP/1.1 connections only, since HTTP/2 multiplexes requests over one connection.
+type ConnPoolStats struct {
+	// Open is a number of currently open connections.
+	Open int64
+	// Active is a number of connections currently used by requests.
+	Active int64
+	// Idle is a number of open connections waiting in the pool.
+	Idle int64
+	// New is a total number of requests that established a new connection.
+	New int64
+	// Reused is a total number of requests that reused a keep-alive connection.
+	Reused int64
+}
+
+// ConnPoolStats returns connection pool usage statistics. Returns zero stats if WithConnPoolStats isn't set.
+func (api *API) ConnPoolStats() ConnPoolStats {
+	if api.connStats == nil {
+		return ConnPoolStats{}
+	}
+	return api.connStats.snapshot()
+}
+
+type connTracker struct {
+	open, active, new, reused int64
+}
+
+func (t *connTracker) snapshot() ConnPoolStats {
+	stats := ConnPoolStats{
+		Open:   atomic.LoadInt64(&t.open),
+		Active: atomic.LoadInt64(&t.active),
+		New:    atomic.LoadInt64(&t.new),
+		Reused: atomic.LoadInt64(&t.reused),
+	}
+	if stats.Active > stats.Open {
+		stats.Active = stats.Open
+	}
+	stats.Idle = stats.Open - stats.Active
+	return stats
+}
+
+// transportOption wraps DialContext of transport to count open connections.
+func (t *connTracker) transportOption(transport *http.Transport) {
+	dial := transport.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		atomic.AddInt64(&t.open, 1)
+		return &trackedConn{Conn: conn, onClose: func() { atomic.AddInt64(&t.open, -1) }}, nil
+	}
+}
+
+// withClientTrace returns request with client trace that tracks connection usage.
+func (t *connTracker) withClientTrace(req *http.Request) *http.Request {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				atomic.AddInt64(&t.reused, 1)
+			} else {
+				atomic.AddInt64(&t.new, 1)
+			}
+			atomic.AddInt64(&t.active, 1)
+		},
+		PutIdleConn: func(error) {
+			// Called either when connection is returned to the pool or when it's closed
+			atomic.AddInt64(&t.active, -1)
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+type trackedConn struct {
+	net.Conn
+	once    sync.Once
+	onClose func()
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(c.onClose)
+	return c.Conn.Close()
+}